@@ -2,251 +2,160 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-)
-
-type MatchResult struct {
-	EndIdx   int
-	Captures []string
-}
+	"strconv"
+	"strings"
 
-func isDigitByte(b byte) bool {
-	return b >= '0' && b <= '9'
-}
+	"github.com/lugiumarra/grep-go/regex"
+)
 
-func isAlphaNumeric(b byte) bool {
-	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_'
+// searchOptions controls how matching lines are reported.
+type searchOptions struct {
+	onlyMatching bool // print each matched part instead of the whole line (-o)
+	namedGroups  bool // follow each matched part with the named groups it captured
+	nullData     bool // records end with NUL instead of newline (-z)
+	abortOnLimit bool // stop the search when a line exceeds --max-steps instead of skipping it
 }
 
-// reverseMatches reverses a slice of MatchResult in place.
-func reverseMatches(s []MatchResult) {
-	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-		s[i], s[j] = s[j], s[i]
+// maxRecordSize bounds a single record, which with -z can be a whole file.
+const maxRecordSize = 1 << 30
+
+// newRecordScanner returns a scanner over the records of r: lines, or with
+// -z NUL-terminated records that may span several lines.
+func newRecordScanner(r io.Reader, opts searchOptions) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	if opts.nullData {
+		scanner.Buffer(nil, maxRecordSize)
+		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.IndexByte(data, 0); i >= 0 {
+				return i + 1, data[:i], nil
+			}
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		})
 	}
+	return scanner
 }
 
-func matchFromChild(children []Node, childIdx int, inputLine string, pos int, caps []string) []MatchResult {
-	// Base case: If we have successfully matched all children, we have a valid result.
-	if childIdx == len(children) {
-		return []MatchResult{{EndIdx: pos, Captures: caps}}
+// recordEnd is printed after each output record.
+func (opts searchOptions) recordEnd() string {
+	if opts.nullData {
+		return "\x00"
 	}
-
-	var allResults []MatchResult
-	// Get the current child node to match.
-	child := children[childIdx]
-	// Find all possible ways the current child can match starting from `pos`.
-	matches := matchPossibilities(child, inputLine, pos, caps)
-
-	// --- FIX: IMPLEMENT GREEDY MATCHING ---
-	// If the child is a greedy quantifier, try the longest match first by reversing the possibilities.
-	if qn, ok := child.(*QuantifierNode); ok && qn.Greed {
-		reverseMatches(matches)
-	}
-
-	for _, res := range matches {
-		recursiveResults := matchFromChild(children, childIdx+1, inputLine, res.EndIdx, res.Captures)
-		allResults = append(allResults, recursiveResults...)
-	}
-	return allResults
+	return "\n"
 }
 
-func matchPossibilities(astNode Node, inputLine string, startIdx int, captures []string) []MatchResult {
-	if astNode == nil {
-		return nil
-	}
-	var results []MatchResult
-	switch node := astNode.(type) {
-	case *LiteralNode:
-		// fmt.Println("LiteralNode with char:", node.Char)
-		if startIdx < len(inputLine) && inputLine[startIdx] == byte(node.Char) {
-			snap := append([]string(nil), captures...)
-			results = append(results, MatchResult{EndIdx: startIdx + 1, Captures: snap})
-		}
-		return results
-	case *CharClassNode:
-		// fmt.Println("CharClassNode with char:", node.Char)
-		if startIdx < len(inputLine) {
-			ch := inputLine[startIdx]
-			ok := (node.Char == 'd' && isDigitByte(ch)) || (node.Char == 'w' && isAlphaNumeric(ch))
-			if ok {
-				snap := append([]string(nil), captures...)
-				results = append(results, MatchResult{EndIdx: startIdx + 1, Captures: snap})
-			}
-		}
-		return results
-	case *CharSetNode:
-		if startIdx < len(inputLine) {
-			ch := rune(inputLine[startIdx])
-			isIn := false
-			for _, char := range node.Chars {
-				if ch == char {
-					isIn = true
-				}
-			}
-			if isIn != node.Negated {
-				snap := append([]string(nil), captures...)
-				results = append(results, MatchResult{EndIdx: startIdx + 1, Captures: snap})
-			}
-		}
-		return results
-	case *AnchorNode:
-		if node.Type == 's' {
-			if startIdx == 0 {
-				return []MatchResult{{EndIdx: startIdx, Captures: captures}}
-			}
-		} else if node.Type == 'e' {
-			if startIdx == len(inputLine) {
-				return []MatchResult{{EndIdx: startIdx, Captures: captures}}
-			}
-		}
-	case *DotNode:
-		// fmt.Println("DotNode")
-		if startIdx < len(inputLine) {
-			return []MatchResult{{EndIdx: startIdx + 1, Captures: captures}}
+// namedGroupFields formats the named groups of a match as tab-separated
+// name=value fields, with an empty value for a group that didn't participate.
+func namedGroupFields(line string, re *regex.Regexp, caps []int) string {
+	var fields strings.Builder
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
 		}
-	case *ConcatenationNode:
-		// fmt.Println("ConcatenationNode with children:", node.NodeChildren)
-		// Start the recursive matching process from the first child (index 0).
-		return matchFromChild(node.NodeChildren, 0, inputLine, startIdx, captures)
-	case *AlternationNode:
-		// fmt.Println("AlternationNode with branches:", node.Branches)
-		var allResults []MatchResult
-		for _, branch := range node.Branches {
-			branchResults := matchPossibilities(branch, inputLine, startIdx, captures)
-			allResults = append(allResults, branchResults...)
+		value := ""
+		if caps[2*i] >= 0 {
+			value = line[caps[2*i]:caps[2*i+1]]
 		}
-		return allResults
-	case *CaptureGroupNode:
-		var results []MatchResult
-		childPoss := matchPossibilities(node.Child, inputLine, startIdx, captures)
-		for _, p := range childPoss {
-			newCaps := make([]string, len(p.Captures))
-			copy(newCaps, p.Captures)
-			for len(newCaps) <= node.Index {
-				newCaps = append(newCaps, "") // append an empty string as a placeholder
-			}
-
-			// store the captured substring
-			newCaps[node.Index] = inputLine[startIdx:p.EndIdx]
+		fmt.Fprintf(&fields, "\t%s=%s", name, value)
+	}
+	return fields.String()
+}
 
-			results = append(results, MatchResult{EndIdx: p.EndIdx, Captures: newCaps})
+// reportLine prints line, or its matched parts with -o, if it matches the pattern.
+// prefix is printed before each output line. It returns whether the line matched,
+// or regex.ErrStepLimit if matching it needed more steps than --max-steps allows.
+func reportLine(line, prefix string, re *regex.Regexp, opts searchOptions) (bool, error) {
+	if !opts.onlyMatching {
+		isMatched, err := re.TryMatchString(line)
+		if isMatched {
+			fmt.Printf("%s%s%s", prefix, line, opts.recordEnd())
 		}
-		return results
-	case *QuantifierNode:
-		var results []MatchResult
-		switch node.Type {
-		case "ZERO_OR_ONE":
-			// Zero occurrences (always a valid match that consumes nothing)
-			results = append(results, MatchResult{EndIdx: startIdx, Captures: captures})
-			// One occurrence
-			oneMatch := matchPossibilities(node.NodeChildren, inputLine, startIdx, captures)
-			results = append(results, oneMatch...)
-			return results
-		case "ZERO_OR_MORE":
-			// Zero occurrences
-			results = append(results, MatchResult{EndIdx: startIdx, Captures: captures})
-			// One or more occurrences
-			firstMatches := matchPossibilities(node.NodeChildren, inputLine, startIdx, captures)
-			for _, res := range firstMatches {
-				// Recursively match more occurrences from the new end position
-				moreMatches := matchPossibilities(node, inputLine, res.EndIdx, res.Captures)
-				results = append(results, moreMatches...)
-			}
-			return results
-		case "ONE_OR_MORE":
-			// Must match at least once
-			firstMatches := matchPossibilities(node.NodeChildren, inputLine, startIdx, captures)
-			for _, res := range firstMatches {
-				// After the first match, it behaves like ZERO_OR_MORE
-				zeroOrMoreNode := NewQuantifierNode(node.NodeChildren, "ZERO_OR_MORE", node.Greed)
-				moreMatches := matchPossibilities(zeroOrMoreNode, inputLine, res.EndIdx, res.Captures)
-				results = append(results, moreMatches...)
-			}
-			return results
+		return isMatched, err
+	}
+
+	lineMatched := false
+	for m, err := range re.AllMatches(line) {
+		if err != nil {
+			return lineMatched, err
 		}
-	case *BackreferenceNode:
-		if node.Index < len(captures) && captures[node.Index] != "" {
-			text := captures[node.Index]
-			if len(inputLine) >= startIdx+len(text) && inputLine[startIdx:startIdx+len(text)] == text {
-				return []MatchResult{{EndIdx: startIdx + len(text), Captures: captures}}
+		lineMatched = true
+		// Empty matches count, but there's nothing to print for them.
+		if m.EndIdx > m.StartIdx {
+			fields := ""
+			if opts.namedGroups {
+				fields = namedGroupFields(line, re, m.Captures)
 			}
+			fmt.Printf("%s%s%s%s", prefix, line[m.StartIdx:m.EndIdx], fields, opts.recordEnd())
 		}
-		return nil
 	}
-	return nil
+	return lineMatched, nil
 }
 
-func matchEntireAst(ast Node, inputLine string, parser *RegexParser) (bool, int, []string) {
-	var startPositions []int
-	if len(parser.pattern) > 0 && parser.pattern[0] == '^' {
-		startPositions = []int{0}
-	} else {
-		startPositions = make([]int, len(inputLine)+1)
-		for i := 0; i <= len(inputLine); i++ {
-			startPositions[i] = i
+// searchRecords reports the matching records read from r. name identifies r
+// in error messages. A record that exceeds the step limit is reported on
+// stderr and skipped, which sets skipped, or with opts.abortOnLimit ends the
+// search with regex.ErrStepLimit.
+func searchRecords(r io.Reader, name, prefix string, re *regex.Regexp, opts searchOptions) (hadMatch, skipped bool, err error) {
+	scanner := newRecordScanner(r, opts)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		matched, lineErr := reportLine(scanner.Text(), prefix, re, opts)
+		if lineErr != nil {
+			fmt.Fprintf(os.Stderr, "mygrep: %s:%d: %v\n", name, lineNum, lineErr)
+			if opts.abortOnLimit {
+				return hadMatch, skipped, lineErr
+			}
+			skipped = true
 		}
-	}
-
-	for _, pos := range startPositions {
-		initialCaps := make([]string, parser.groupCount+1)
-
-		possibilities := matchPossibilities(ast, inputLine, pos, initialCaps)
-
-		if len(possibilities) > 0 {
-			return true, possibilities[0].EndIdx, possibilities[0].Captures
+		if matched {
+			hadMatch = true
 		}
 	}
-	return false, -1, nil
+	return hadMatch, skipped, scanner.Err()
 }
 
-func searchFile(filename string, ast Node, parser *RegexParser, printFilenames bool) (bool, error) {
+func searchFile(filename string, re *regex.Regexp, printFilenames bool, opts searchOptions) (bool, bool, error) {
 	/*
-			Searches a single file for the pattern defined by the AST.
+			Searches a single file for the pattern.
 
 		    Returns:
-		        True if a match was found in this file, False otherwise.
+		        True if a match was found in this file, False otherwise,
+		        and whether any line was skipped at the step limit.
 	*/
 	file, openErr := os.Open(filename)
 	if openErr != nil {
-		return false, openErr
+		return false, false, openErr
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	fileHadMatch := false
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		isMatched, _, _ := matchEntireAst(ast, line, parser)
-		if isMatched {
-			if printFilenames {
-				fmt.Printf("%s:%s\n", filename, line)
-			} else {
-				fmt.Println(line)
-			}
-			fileHadMatch = true
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return fileHadMatch, err
+	prefix := ""
+	if printFilenames {
+		prefix = filename + ":"
 	}
-
-	return fileHadMatch, nil
+	return searchRecords(file, filename, prefix, re, opts)
 }
 
 // searchRecursive walks a directory and searches all files within it.
-func searchRecursive(root string, ast Node, parser *RegexParser) (bool, error) {
+func searchRecursive(root string, re *regex.Regexp, opts searchOptions) (bool, bool, error) {
 	anyMatchFound := false
+	anySkipped := false
 	walkErr := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err // Propagate errors from walking the path
 		}
 		if !info.IsDir() {
 			// Always print filenames in recursive mode
-			fileHadMatch, searchErr := searchFile(path, ast, parser, true)
+			fileHadMatch, fileSkipped, searchErr := searchFile(path, re, true, opts)
+			if errors.Is(searchErr, regex.ErrStepLimit) {
+				return searchErr
+			}
 			if searchErr != nil {
 				// Silently ignore errors on individual files
 				return nil
@@ -254,10 +163,30 @@ func searchRecursive(root string, ast Node, parser *RegexParser) (bool, error) {
 			if fileHadMatch {
 				anyMatchFound = true
 			}
+			if fileSkipped {
+				anySkipped = true
+			}
 		}
 		return nil
 	})
-	return anyMatchFound, walkErr
+	return anyMatchFound, anySkipped, walkErr
+}
+
+// printCaret shows the pattern with a caret under the position of the error.
+func printCaret(parseErr *regex.ParseError) {
+	var pad strings.Builder
+	for i, r := range []rune(parseErr.Pattern) {
+		if i == parseErr.Offset {
+			break
+		}
+		// Keep tabs so the caret stays under the right character.
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+	fmt.Fprintf(os.Stderr, "  %s\n  %s^\n", parseErr.Pattern, pad.String())
 }
 
 // Usage: echo <input_text> | your_program.sh -E <pattern>
@@ -267,12 +196,47 @@ func main() {
 	var patternStr string
 	var paths []string
 	recursive := false
+	var opts searchOptions
+	var flags regex.Flags
+	maxSteps := 0
 
 	// Manual argument parsing loop
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		if arg == "-r" {
 			recursive = true
+		} else if arg == "-o" {
+			opts.onlyMatching = true
+		} else if arg == "--named-groups" {
+			// Named groups are reported alongside each matched part.
+			opts.onlyMatching = true
+			opts.namedGroups = true
+		} else if arg == "-z" || arg == "--null-data" {
+			opts.nullData = true
+		} else if arg == "--multiline" {
+			flags |= regex.FlagMultiline
+		} else if arg == "-i" || arg == "--ignore-case" {
+			flags |= regex.FlagFoldCase
+		} else if arg == "--smart-case" {
+			flags |= regex.FlagSmartCase
+		} else if arg == "--max-steps" {
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "error: --max-steps requires a number")
+				os.Exit(2)
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "error: invalid --max-steps value %q\n", args[i+1])
+				os.Exit(2)
+			}
+			maxSteps = n
+			i++
+		} else if arg == "--abort-on-limit" {
+			opts.abortOnLimit = true
+		} else if arg == "--posix" {
+			flags |= regex.FlagLongest
+		} else if arg == "--unicode" {
+			flags |= regex.FlagUnicodeClasses
 		} else if arg == "-E" {
 			if i+1 < len(args) {
 				patternStr = args[i+1]
@@ -291,31 +255,33 @@ func main() {
 	}
 
 	if patternStr == "" {
-		fmt.Fprintf(os.Stderr, "usage: mygrep [-r] -E <pattern> [file...]\n")
+		fmt.Fprintf(os.Stderr, "usage: mygrep [-r] [-o | --named-groups] [-z] [-i | --smart-case] [--unicode] [--multiline] [--posix] [--max-steps N [--abort-on-limit]] -E <pattern> [file...]\n")
 		os.Exit(2)
 	}
 
 	// --- 2. Main Logic ---
-	parser := NewRegexParser(patternStr)
-	ast, err := parser.parse()
+	re, err := regex.Compile(patternStr, flags)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: invalid pattern: %v\n", err)
+		var parseErr *regex.ParseError
+		if errors.As(err, &parseErr) {
+			printCaret(parseErr)
+		}
 		os.Exit(2)
 	}
+	re.SetMaxSteps(maxSteps)
 
 	// Case 1: No paths provided, read from standard input.
 	if len(paths) == 0 {
-		scanner := bufio.NewScanner(os.Stdin)
-		anyMatchFound := false
-		for scanner.Scan() {
-			line := scanner.Text()
-			isMatched, _, _ := matchEntireAst(ast, line, parser)
-			if isMatched {
-				fmt.Println(line)
-				anyMatchFound = true
-			}
+		anyMatchFound, skipped, err := searchRecords(os.Stdin, "(standard input)", "", re, opts)
+		if errors.Is(err, regex.ErrStepLimit) {
+			os.Exit(2)
 		}
 		if !anyMatchFound {
+			// A skipped line might have matched, so "no match" isn't certain.
+			if skipped {
+				os.Exit(2)
+			}
 			os.Exit(1)
 		}
 		os.Exit(0)
@@ -324,6 +290,7 @@ func main() {
 	// Case 2: Paths are provided.
 	printFilenames := recursive || len(paths) > 1
 	overallMatchFound := false
+	overallSkipped := false
 
 	for _, path := range paths {
 		info, err := os.Stat(path)
@@ -332,15 +299,21 @@ func main() {
 			continue
 		}
 
-		var pathHadMatch bool
+		var pathHadMatch, pathSkipped bool
 		var searchErr error
 
 		if info.IsDir() && recursive {
-			pathHadMatch, searchErr = searchRecursive(path, ast, parser)
+			pathHadMatch, pathSkipped, searchErr = searchRecursive(path, re, opts)
 		} else if !info.IsDir() {
-			pathHadMatch, searchErr = searchFile(path, ast, parser, printFilenames)
+			pathHadMatch, pathSkipped, searchErr = searchFile(path, re, printFilenames, opts)
 		}
 
+		if errors.Is(searchErr, regex.ErrStepLimit) {
+			os.Exit(2)
+		}
+		if pathSkipped {
+			overallSkipped = true
+		}
 		if searchErr != nil {
 			continue // Silently skip
 		}
@@ -350,6 +323,10 @@ func main() {
 	}
 
 	if !overallMatchFound {
+		// A skipped line might have matched, so "no match" isn't certain.
+		if overallSkipped {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 	os.Exit(0)
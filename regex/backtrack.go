@@ -0,0 +1,490 @@
+package regex
+
+import (
+	"errors"
+	"iter"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MatchResult is one way a node can match, from StartIdx up to EndIdx.
+// Captures holds a start and end byte offset for every group, 2*i and 2*i+1
+// for group i, or -1 when unset. Group 0 is the whole match once a search
+// has found one.
+type MatchResult struct {
+	StartIdx int
+	EndIdx   int
+	Captures []int
+}
+
+// newCaptures returns capture slots for groupCount groups plus the whole match, all unset.
+func newCaptures(groupCount int) []int {
+	caps := make([]int, 2*(groupCount+1))
+	for i := range caps {
+		caps[i] = -1
+	}
+	return caps
+}
+
+// ErrStepLimit is returned by the Try methods when a search needs more
+// backtracking steps than SetMaxSteps allows.
+var ErrStepLimit = errors.New("backtracking step limit exceeded")
+
+// stepBudget bounds the work the backtracker does in one search, so a pattern
+// like '(a+)+$' can't run for ages on a long run of a's. The Pike VM and the
+// lazy DFA are linear in the line length and don't need it.
+type stepBudget struct {
+	limit int // no limit if 0
+	steps int
+}
+
+func newStepBudget(limit int) *stepBudget {
+	return &stepBudget{limit: limit}
+}
+
+// spend counts one step and reports whether the budget allows it.
+func (b *stepBudget) spend() bool {
+	b.steps++
+	return b.limit <= 0 || b.steps <= b.limit
+}
+
+// err returns ErrStepLimit once the budget has been exceeded.
+func (b *stepBudget) err() error {
+	if b.limit > 0 && b.steps > b.limit {
+		return ErrStepLimit
+	}
+	return nil
+}
+
+func isDigitByte(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isAlphaNumeric(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_'
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\v' || b == '\f' || b == '\r'
+}
+
+// posixClasses maps the names allowed in '[:name:]' to their ASCII definitions.
+var posixClasses = map[string]func(byte) bool{
+	"alnum":  func(b byte) bool { return isAlphaNumeric(b) && b != '_' },
+	"alpha":  func(b byte) bool { return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') },
+	"blank":  func(b byte) bool { return b == ' ' || b == '\t' },
+	"cntrl":  func(b byte) bool { return b < 0x20 || b == 0x7f },
+	"digit":  isDigitByte,
+	"graph":  func(b byte) bool { return b > 0x20 && b < 0x7f },
+	"lower":  func(b byte) bool { return b >= 'a' && b <= 'z' },
+	"print":  func(b byte) bool { return b >= 0x20 && b < 0x7f },
+	"punct":  func(b byte) bool { return b > 0x20 && b < 0x7f && (!isAlphaNumeric(b) || b == '_') },
+	"space":  isSpaceByte,
+	"upper":  func(b byte) bool { return b >= 'A' && b <= 'Z' },
+	"xdigit": func(b byte) bool { return isDigitByte(b) || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F') },
+}
+
+// isUnicodeWord reports whether r is a word character under Unicode rules:
+// a letter, mark, decimal digit or connector punctuation such as '_'.
+func isUnicodeWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r) || unicode.Is(unicode.Pc, r)
+}
+
+// shorthandMatches reports whether r belongs to \d, \w or \s, or to \D, \W or \S.
+func shorthandMatches(cls *charClassNode, r rune) bool {
+	var isIn bool
+	switch unicode.ToLower(cls.Char) {
+	case 'd':
+		if cls.Unicode {
+			isIn = unicode.IsDigit(r)
+		} else {
+			isIn = r < utf8.RuneSelf && isDigitByte(byte(r))
+		}
+	case 'w':
+		if cls.Unicode {
+			isIn = isUnicodeWord(r)
+		} else {
+			isIn = r < utf8.RuneSelf && isAlphaNumeric(byte(r))
+		}
+	case 's':
+		if cls.Unicode {
+			isIn = unicode.IsSpace(r)
+		} else {
+			isIn = r < utf8.RuneSelf && isSpaceByte(byte(r))
+		}
+	}
+	// The upper-case forms are the negated classes.
+	return isIn != unicode.IsUpper(cls.Char)
+}
+
+// classMatches reports whether r belongs to a class node such as \d or [:alpha:].
+func classMatches(class regexNode, r rune) bool {
+	switch cls := class.(type) {
+	case *charClassNode:
+		return shorthandMatches(cls, r)
+	case *posixClassNode:
+		return (r < utf8.RuneSelf && posixClasses[cls.Name](byte(r))) != cls.Negated
+	case *unicodeClassNode:
+		return unicode.Is(cls.Table, r) != cls.Negated
+	}
+	return false
+}
+
+// equalFold reports whether a and b are equal under Unicode simple case folding.
+func equalFold(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if f == b {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFoldedText matches text at pos ignoring case and returns the end of the match.
+// The match can differ in byte length from text, as with 'k' and the Kelvin sign.
+func matchFoldedText(inputLine string, pos int, text string) (int, bool) {
+	for _, want := range text {
+		if pos >= len(inputLine) {
+			return 0, false
+		}
+		got, size := utf8.DecodeRuneInString(inputLine[pos:])
+		if !equalFold(got, want) {
+			return 0, false
+		}
+		pos += size
+	}
+	return pos, true
+}
+
+// charSetContains reports whether r is a member of the set, ignoring negation.
+// With FoldCase, r is a member if any rune in its case-folding orbit is.
+func charSetContains(node *charSetNode, r rune) bool {
+	if charSetContainsExact(node, r) {
+		return true
+	}
+	if node.FoldCase {
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if charSetContainsExact(node, f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// charSetContainsExact reports whether r itself is listed in the set.
+func charSetContainsExact(node *charSetNode, r rune) bool {
+	for _, char := range node.Chars {
+		if r == char {
+			return true
+		}
+	}
+	for _, rng := range node.Ranges {
+		if r >= rng.Lo && r <= rng.Hi {
+			return true
+		}
+	}
+	for _, class := range node.Classes {
+		if classMatches(class, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWordBoundary reports whether pos sits between a word character and a
+// non-word character, treating both ends of the line as non-word. With
+// unicodeWords, word characters are those \w accepts under --unicode.
+func isWordBoundary(inputLine string, pos int, unicodeWords bool) bool {
+	if !unicodeWords {
+		before := pos > 0 && isAlphaNumeric(inputLine[pos-1])
+		after := pos < len(inputLine) && isAlphaNumeric(inputLine[pos])
+		return before != after
+	}
+	before, after := false, false
+	if pos > 0 {
+		r, _ := utf8.DecodeLastRuneInString(inputLine[:pos])
+		before = isUnicodeWord(r)
+	}
+	if pos < len(inputLine) {
+		r, _ := utf8.DecodeRuneInString(inputLine[pos:])
+		after = isUnicodeWord(r)
+	}
+	return before != after
+}
+
+// matchesRune reports whether a node that consumes a single code point accepts r.
+func matchesRune(node regexNode, r rune) bool {
+	switch n := node.(type) {
+	case *literalNode:
+		return r == n.Char || (n.FoldCase && equalFold(r, n.Char))
+	case *charClassNode:
+		return shorthandMatches(n, r)
+	case *unicodeClassNode:
+		return classMatches(n, r)
+	case *charSetNode:
+		return charSetContains(n, r) != n.Negated
+	case *dotNode:
+		return n.DotAll || r != '\n'
+	}
+	return false
+}
+
+// anchorMatches reports whether a zero-width anchor holds at pos.
+func anchorMatches(node *anchorNode, inputLine string, pos int) bool {
+	switch node.Type {
+	case 's':
+		return pos == 0 || (node.Multiline && inputLine[pos-1] == '\n')
+	case 'e':
+		return pos == len(inputLine) || (node.Multiline && inputLine[pos] == '\n')
+	case 'A':
+		return pos == 0
+	case 'z':
+		return pos == len(inputLine)
+	case 'Z':
+		return pos == len(inputLine) || (pos == len(inputLine)-1 && inputLine[pos] == '\n')
+	case 'b', 'B':
+		return isWordBoundary(inputLine, pos, node.Unicode) == (node.Type == 'b')
+	}
+	return false
+}
+
+// matchFromChild yields the ways children[childIdx:] can match one after
+// another from pos, in preference order.
+func matchFromChild(children []regexNode, childIdx int, inputLine string, pos int, caps []int, budget *stepBudget) iter.Seq[MatchResult] {
+	return func(yield func(MatchResult) bool) {
+		// Base case: If we have successfully matched all children, we have a valid result.
+		if childIdx == len(children) {
+			yield(MatchResult{EndIdx: pos, Captures: caps})
+			return
+		}
+
+		// Try the rest of the children after each way the current child can match.
+		for res := range matchPossibilities(children[childIdx], inputLine, pos, caps, budget) {
+			for rest := range matchFromChild(children, childIdx+1, inputLine, res.EndIdx, res.Captures, budget) {
+				if !yield(rest) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// matchRepeat expands a quantifier that has already matched its child count times.
+// Greedy quantifiers yield the longer expansions first, lazy ones the shorter.
+func matchRepeat(node *quantifierNode, inputLine string, pos int, caps []int, count int, budget *stepBudget) iter.Seq[MatchResult] {
+	return func(yield func(MatchResult) bool) {
+		canStop := count >= node.Min
+		canGrow := node.Max == unboundedRepeat || count < node.Max
+		stop := MatchResult{EndIdx: pos, Captures: caps}
+
+		if canStop && !node.Greed && !yield(stop) {
+			return
+		}
+		if canGrow {
+			for res := range matchPossibilities(node.NodeChildren, inputLine, pos, caps, budget) {
+				// As in Perl, an iteration that consumes nothing ends the loop once the
+				// minimum is met, so another iteration from the same place is never
+				// tried and an unbounded loop can't recurse forever. The Pike VM
+				// follows the same rule; see compileIteration.
+				if res.EndIdx == pos && count+1 >= node.Min {
+					if !yield(MatchResult{EndIdx: pos, Captures: res.Captures}) {
+						return
+					}
+					continue
+				}
+				for more := range matchRepeat(node, inputLine, res.EndIdx, res.Captures, count+1, budget) {
+					if !yield(more) {
+						return
+					}
+				}
+			}
+		}
+		if canStop && node.Greed {
+			yield(stop)
+		}
+	}
+}
+
+// matchLookaround checks a lookaround assertion at pos without consuming input.
+// A positive assertion keeps the captures its child made; a negative one can't have any.
+func matchLookaround(node *lookaroundNode, inputLine string, pos int, caps []int, budget *stepBudget) iter.Seq[MatchResult] {
+	return func(yield func(MatchResult) bool) {
+		var found MatchResult
+		ok := false
+		if node.Ahead {
+			found, ok = firstMatch(matchPossibilities(node.Child, inputLine, pos, caps, budget))
+		} else {
+			// Try every start within MaxLen runes before pos, nearest first, and take
+			// the first match that ends exactly at pos.
+			start := pos
+			for back := 0; back <= node.MaxLen && !ok; back++ {
+				for res := range matchPossibilities(node.Child, inputLine, start, caps, budget) {
+					if res.EndIdx == pos {
+						found, ok = res, true
+						break
+					}
+				}
+				if start == 0 {
+					break
+				}
+				_, size := utf8.DecodeLastRuneInString(inputLine[:start])
+				start -= size
+			}
+		}
+
+		if node.Negated {
+			if !ok {
+				yield(MatchResult{EndIdx: pos, Captures: caps})
+			}
+			return
+		}
+		if ok {
+			yield(MatchResult{EndIdx: pos, Captures: found.Captures})
+		}
+	}
+}
+
+// firstMatch returns the preferred result of seq without generating the rest.
+func firstMatch(seq iter.Seq[MatchResult]) (MatchResult, bool) {
+	for res := range seq {
+		return res, true
+	}
+	return MatchResult{}, false
+}
+
+// matchPossibilities yields every way astNode can match at startIdx, in the
+// order a leftmost-first matcher prefers them. The results are produced on
+// demand, so a caller that stops early never pays for the alternatives.
+func matchPossibilities(astNode regexNode, inputLine string, startIdx int, captures []int, budget *stepBudget) iter.Seq[MatchResult] {
+	return func(yield func(MatchResult) bool) {
+		if !budget.spend() {
+			// Out of steps: give up on this search. The caller checks budget.err().
+			return
+		}
+		for res := range matchNode(astNode, inputLine, startIdx, captures, budget) {
+			res.StartIdx = startIdx
+			if !yield(res) {
+				return
+			}
+		}
+	}
+}
+
+// matchNode does the work of matchPossibilities for each kind of node.
+func matchNode(astNode regexNode, inputLine string, startIdx int, captures []int, budget *stepBudget) iter.Seq[MatchResult] {
+	return func(yield func(MatchResult) bool) {
+		switch node := astNode.(type) {
+		case nil:
+			// An empty group body matches the empty string.
+			yield(MatchResult{EndIdx: startIdx, Captures: captures})
+		case *literalNode, *charClassNode, *charSetNode, *dotNode, *unicodeClassNode:
+			// Each of these consumes exactly one code point. Invalid UTF-8 decodes as
+			// utf8.RuneError one byte at a time, so it can match '.', negated classes
+			// and sets, or a literal U+FFFD.
+			if startIdx < len(inputLine) {
+				r, size := utf8.DecodeRuneInString(inputLine[startIdx:])
+				if matchesRune(node, r) {
+					yield(MatchResult{EndIdx: startIdx + size, Captures: captures})
+				}
+			}
+		case *anchorNode:
+			if anchorMatches(node, inputLine, startIdx) {
+				yield(MatchResult{EndIdx: startIdx, Captures: captures})
+			}
+		case *concatenationNode:
+			// Start the recursive matching process from the first child (index 0).
+			matchFromChild(node.NodeChildren, 0, inputLine, startIdx, captures, budget)(yield)
+		case *alternationNode:
+			for _, branch := range node.Branches {
+				for res := range matchPossibilities(branch, inputLine, startIdx, captures, budget) {
+					if !yield(res) {
+						return
+					}
+				}
+			}
+		case *captureGroupNode:
+			for p := range matchPossibilities(node.Child, inputLine, startIdx, captures, budget) {
+				newCaps := make([]int, len(p.Captures))
+				copy(newCaps, p.Captures)
+
+				// store the bounds of the captured substring
+				newCaps[2*node.Index] = startIdx
+				newCaps[2*node.Index+1] = p.EndIdx
+
+				if !yield(MatchResult{EndIdx: p.EndIdx, Captures: newCaps}) {
+					return
+				}
+			}
+		case *groupNode:
+			matchPossibilities(node.Child, inputLine, startIdx, captures, budget)(yield)
+		case *lookaroundNode:
+			matchLookaround(node, inputLine, startIdx, captures, budget)(yield)
+		case *atomicGroupNode:
+			// Commit to the preferred match; the alternatives are never generated.
+			if res, ok := firstMatch(matchPossibilities(node.Child, inputLine, startIdx, captures, budget)); ok {
+				yield(res)
+			}
+		case *quantifierNode:
+			repeats := matchRepeat(node, inputLine, startIdx, captures, 0, budget)
+			if !node.Possessive {
+				repeats(yield)
+			} else if res, ok := firstMatch(repeats); ok {
+				// A possessive quantifier is an atomic group around a greedy one.
+				yield(res)
+			}
+		case *backreferenceNode:
+			// A group that hasn't taken part in the match can't be referred to.
+			if 2*node.Index+1 < len(captures) && captures[2*node.Index] >= 0 {
+				text := inputLine[captures[2*node.Index]:captures[2*node.Index+1]]
+				if node.FoldCase {
+					if end, ok := matchFoldedText(inputLine, startIdx, text); ok {
+						yield(MatchResult{EndIdx: end, Captures: captures})
+					}
+				} else if len(inputLine) >= startIdx+len(text) && inputLine[startIdx:startIdx+len(text)] == text {
+					yield(MatchResult{EndIdx: startIdx + len(text), Captures: captures})
+				}
+			}
+		}
+	}
+}
+
+// longestMatch picks the POSIX leftmost-longest result of seq, whose matches
+// all start at the same position: the one that ends last, and among those the one
+// whose groups, taken in order, start earliest and then end last.
+// Ties keep the result a leftmost-first matcher would prefer. Only the best
+// so far is kept, so memory doesn't grow with the number of results.
+func longestMatch(seq iter.Seq[MatchResult]) (MatchResult, bool) {
+	var best MatchResult
+	found := false
+	for res := range seq {
+		if !found || posixPrefers(res, best) {
+			best, found = res, true
+		}
+	}
+	return best, found
+}
+
+// posixPrefers reports whether a beats b under POSIX rules. A group that took
+// part in the match beats one that didn't.
+func posixPrefers(a, b MatchResult) bool {
+	if a.EndIdx != b.EndIdx {
+		return a.EndIdx > b.EndIdx
+	}
+	for i := 2; i+1 < len(a.Captures); i += 2 {
+		aStart, bStart := a.Captures[i], b.Captures[i]
+		if aStart != bStart {
+			if aStart < 0 || bStart < 0 {
+				return bStart < 0
+			}
+			return aStart < bStart
+		}
+		if aEnd, bEnd := a.Captures[i+1], b.Captures[i+1]; aEnd != bEnd {
+			return aEnd > bEnd
+		}
+	}
+	return false
+}
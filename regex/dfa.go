@@ -0,0 +1,258 @@
+package regex
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// The lazy DFA answers "does this line match anywhere?" without tracking
+// captures or match positions. Its states are sets of Pike VM instructions,
+// built on demand the first time a transition is needed and cached after
+// that, so a scan over a long file mostly follows cached transitions.
+//
+// Only patterns whose assertions are '^', '$', '\A' and '\z' outside
+// multiline mode can use it. The state cache is bounded; when it is full it
+// is flushed and rebuilt as the scan goes on, as RE2 does. A line that fills
+// the cache again after a flush needs too many states, and the caller falls
+// back to the Pike VM or the backtracker for it.
+//
+// Scans share the cache. Following a cached ASCII transition takes no lock;
+// other runes take a read lock, and building a state or transition takes the
+// write lock.
+
+// maxDFAStates bounds the number of cached states.
+const maxDFAStates = 4096
+
+// maxDFAFlushes is how many times one scan may flush the cache before giving up.
+const maxDFAFlushes = 1
+
+type dfaState struct {
+	pcs      []int // sorted instructions threads are waiting at: runes, matches and end assertions
+	matched  bool  // a thread has reached instMatch
+	endMatch bool  // a thread would match if the input ended here
+	ascii    [utf8.RuneSelf]atomic.Pointer[dfaState]
+	other    map[rune]*dfaState // guarded by lazyDFA.mu
+}
+
+type lazyDFA struct {
+	mu      sync.RWMutex // guards states, start and the other maps of states
+	prog    *program
+	states  map[string]*dfaState
+	start   *dfaState
+	restart []int // where a fresh unanchored attempt begins after the first position
+
+	// emptyMatch records whether the pattern matches an empty line, where
+	// start and end assertions both hold at the same position.
+	emptyMatch bool
+}
+
+// newLazyDFA returns a DFA for prog, or nil if prog is nil or uses an
+// assertion the DFA can't handle.
+func newLazyDFA(prog *program) *lazyDFA {
+	if prog == nil {
+		return nil
+	}
+	for _, in := range prog.insts {
+		if in.op == instNoRune {
+			// Looking at the next rune without consuming it isn't a DFA transition.
+			return nil
+		}
+		if in.op != instAssert {
+			continue
+		}
+		anchor := in.node.(*anchorNode)
+		switch anchor.Type {
+		case 'A', 'z':
+		case 's', 'e':
+			if anchor.Multiline {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+
+	d := &lazyDFA{prog: prog, states: make(map[string]*dfaState)}
+	d.restart = d.closure([]int{0}, false)
+	d.start = d.state(d.closure([]int{0}, true))
+	d.emptyMatch = d.matchesAtEnd([]int{0}, true)
+	return d
+}
+
+// closure follows jumps, splits, saves and start assertions from pcs and
+// returns the sorted instructions reached that consume input or wait for the end.
+func (d *lazyDFA) closure(pcs []int, atStart bool) []int {
+	seen := make(map[int]bool)
+	var out []int
+	var visit func(pc int)
+	visit = func(pc int) {
+		if seen[pc] {
+			return
+		}
+		seen[pc] = true
+		in := d.prog.insts[pc]
+		switch in.op {
+		case instJmp:
+			visit(in.x)
+		case instSplit:
+			visit(in.x)
+			visit(in.y)
+		case instSave:
+			visit(pc + 1)
+		case instAssert:
+			switch in.node.(*anchorNode).Type {
+			case 's', 'A':
+				if atStart {
+					visit(pc + 1)
+				}
+			default:
+				out = append(out, pc)
+			}
+		default:
+			out = append(out, pc)
+		}
+	}
+	for _, pc := range pcs {
+		visit(pc)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// state returns the cached state for pcs, creating it if there is room.
+// It returns nil once the cache is full. The caller holds d.mu for writing.
+func (d *lazyDFA) state(pcs []int) *dfaState {
+	var key strings.Builder
+	for _, pc := range pcs {
+		key.WriteString(strconv.Itoa(pc))
+		key.WriteByte(',')
+	}
+	if st, ok := d.states[key.String()]; ok {
+		return st
+	}
+	if len(d.states) >= maxDFAStates {
+		return nil
+	}
+
+	st := &dfaState{pcs: pcs}
+	var atEnd []int
+	for _, pc := range pcs {
+		switch d.prog.insts[pc].op {
+		case instMatch:
+			st.matched = true
+		case instAssert:
+			atEnd = append(atEnd, pc+1)
+		}
+	}
+	st.endMatch = st.matched || d.matchesAtEnd(atEnd, false)
+	d.states[key.String()] = st
+	return st
+}
+
+// matchesAtEnd reports whether threads at pcs reach instMatch without
+// consuming anything more when the input ends here.
+func (d *lazyDFA) matchesAtEnd(pcs []int, atStart bool) bool {
+	passed := make(map[int]bool)
+	for len(pcs) > 0 {
+		var next []int
+		for _, pc := range d.closure(pcs, atStart) {
+			switch d.prog.insts[pc].op {
+			case instMatch:
+				return true
+			case instAssert:
+				// Every end assertion holds at the end, including repeated ones as in '$$'.
+				if !passed[pc] {
+					passed[pc] = true
+					next = append(next, pc+1)
+				}
+			}
+		}
+		pcs = next
+	}
+	return false
+}
+
+// step returns the state after consuming r from st. flushed reports whether
+// the cache was full and had to be flushed to make room for it.
+func (d *lazyDFA) step(st *dfaState, r rune) (next *dfaState, flushed bool) {
+	if r < utf8.RuneSelf {
+		if next := st.ascii[r].Load(); next != nil {
+			return next, false
+		}
+	} else {
+		d.mu.RLock()
+		next := st.other[r]
+		d.mu.RUnlock()
+		if next != nil {
+			return next, false
+		}
+	}
+
+	var targets []int
+	for _, pc := range st.pcs {
+		in := d.prog.insts[pc]
+		if in.op == instRune && matchesRune(in.node, r) {
+			targets = append(targets, pc+1)
+		}
+	}
+	targets = append(targets, d.restart...)
+	pcs := d.closure(targets, false)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if next = d.state(pcs); next == nil {
+		d.flush()
+		flushed = true
+		next = d.state(pcs)
+	}
+
+	if r < utf8.RuneSelf {
+		st.ascii[r].Store(next)
+	} else {
+		if st.other == nil {
+			st.other = make(map[rune]*dfaState)
+		}
+		st.other[r] = next
+	}
+	return next, flushed
+}
+
+// flush empties the cache and rebuilds the start state. States a scan still
+// holds stay usable; they are just no longer shared with later scans.
+// The caller holds d.mu for writing.
+func (d *lazyDFA) flush() {
+	d.states = make(map[string]*dfaState)
+	d.start = d.state(d.closure([]int{0}, true))
+}
+
+// matches reports whether the pattern matches anywhere in inputLine.
+// ok is false if the line needed more states than the cache holds and the
+// caller must fall back.
+func (d *lazyDFA) matches(inputLine string) (matched bool, ok bool) {
+	if len(inputLine) == 0 {
+		return d.emptyMatch, true
+	}
+
+	d.mu.RLock()
+	st := d.start
+	d.mu.RUnlock()
+	flushes := 0
+	for pos := 0; pos < len(inputLine); {
+		if st.matched {
+			return true, true
+		}
+		r, size := utf8.DecodeRuneInString(inputLine[pos:])
+		var flushed bool
+		if st, flushed = d.step(st, r); flushed {
+			if flushes++; flushes > maxDFAFlushes {
+				return false, false
+			}
+		}
+		pos += size
+	}
+	return st.endMatch, true
+}
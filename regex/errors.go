@@ -0,0 +1,47 @@
+package regex
+
+import "fmt"
+
+// ErrorKind says what is wrong with a pattern. Its value is the start of the
+// error message.
+type ErrorKind string
+
+const (
+	ErrTrailingBackslash     ErrorKind = "trailing backslash at end of pattern"
+	ErrMissingParen          ErrorKind = "missing closing ')'"
+	ErrUnmatchedParen        ErrorKind = "unmatched ')'"
+	ErrEmptyGroup            ErrorKind = "empty group"
+	ErrInvalidGroup          ErrorKind = "unknown group syntax"
+	ErrInvalidGroupName      ErrorKind = "invalid group name"
+	ErrDuplicateGroupName    ErrorKind = "duplicate group name"
+	ErrInvalidFlag           ErrorKind = "invalid flag group"
+	ErrUnboundedLookbehind   ErrorKind = "lookbehind must match a bounded number of characters"
+	ErrEmptyAlternative      ErrorKind = "empty alternative"
+	ErrMissingRepeatArgument ErrorKind = "nothing to repeat"
+	ErrInvalidRepeat         ErrorKind = "invalid repetition"
+	ErrUnterminatedSet       ErrorKind = "unterminated character set"
+	ErrInvalidRange          ErrorKind = "invalid character range"
+	ErrInvalidPosixClass     ErrorKind = "invalid POSIX class"
+	ErrInvalidUnicodeClass   ErrorKind = "invalid Unicode class"
+	ErrInvalidBackref        ErrorKind = "invalid backreference"
+)
+
+// ParseError describes a pattern that failed to parse.
+type ParseError struct {
+	Kind    ErrorKind
+	Detail  string // more about this particular error, if anything
+	Offset  int    // rune offset of the problem in Pattern
+	Pattern string
+}
+
+func (e *ParseError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%s at position %d", e.Kind, e.Offset)
+	}
+	return fmt.Sprintf("%s at position %d: %s", e.Kind, e.Offset, e.Detail)
+}
+
+// errorAt returns a ParseError of the given kind at rune offset in the pattern.
+func (rp *parser) errorAt(offset int, kind ErrorKind, detail string) error {
+	return &ParseError{Kind: kind, Detail: detail, Offset: offset, Pattern: string(rp.pattern)}
+}
@@ -0,0 +1,343 @@
+package regex
+
+import (
+	"fmt"
+	"unicode"
+)
+
+type regexNode interface {
+	// String returns a string representation of the node for debugging.
+	String() string
+
+	// Children returns the child nodes oof this node
+	Children() []regexNode
+}
+
+// ------------------------------------------------------------------------------------------
+
+type dotNode struct {
+	DotAll bool // also match '\n'
+}
+
+func newDotNode(dotAll bool) *dotNode {
+	return &dotNode{DotAll: dotAll}
+}
+
+func (dn *dotNode) String() string {
+	return fmt.Sprintf("dotNode(dotAll='%v')", dn.DotAll)
+}
+
+func (dn *dotNode) Children() []regexNode {
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------
+
+type literalNode struct {
+	Char     rune
+	FoldCase bool // compare using Unicode simple case folding
+}
+
+func newLiteralNode(char rune, foldCase bool) *literalNode {
+	return &literalNode{Char: char, FoldCase: foldCase}
+}
+
+func (ln *literalNode) String() string {
+	return fmt.Sprintf("literalNode('%c', foldCase='%v')", ln.Char, ln.FoldCase)
+}
+
+// A literal is a leaf node, so it has no children.
+func (ln *literalNode) Children() []regexNode {
+	return nil // Returning nil is efficient and idiomatic.
+}
+
+// ------------------------------------------------------------------------------------------
+
+// charClassNode is a shorthand class: d, w and s, or D, W and S for their negations.
+type charClassNode struct {
+	Char    rune
+	Unicode bool // use Unicode categories instead of ASCII
+}
+
+func newCharClassNode(char rune, unicodeClasses bool) *charClassNode {
+	return &charClassNode{Char: char, Unicode: unicodeClasses}
+}
+
+func (ccn *charClassNode) String() string {
+	return fmt.Sprintf("charClassNode(type='%c', unicode='%v')", ccn.Char, ccn.Unicode)
+}
+
+func (ccn *charClassNode) Children() []regexNode {
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------
+
+type concatenationNode struct {
+	NodeChildren []regexNode
+}
+
+func newConcatenationNode(children []regexNode) *concatenationNode {
+	return &concatenationNode{NodeChildren: children}
+}
+
+func (cn *concatenationNode) String() string {
+	return fmt.Sprintf("concatenationNode('%v')", cn.NodeChildren)
+}
+
+func (cn *concatenationNode) Children() []regexNode {
+	return cn.NodeChildren
+}
+
+// ------------------------------------------------------------------------------------------
+
+// runeRange is an inclusive range of runes such as a-z inside a bracket expression.
+type runeRange struct {
+	Lo rune
+	Hi rune
+}
+
+type charSetNode struct {
+	Chars    []rune
+	Ranges   []runeRange
+	Classes  []regexNode // class escapes like \d that appear inside the brackets
+	Negated  bool
+	FoldCase bool // a rune is a member if any case variant of it is
+}
+
+func newCharSetNode(chars []rune, ranges []runeRange, classes []regexNode, negated, foldCase bool) *charSetNode {
+	return &charSetNode{Chars: chars, Ranges: ranges, Classes: classes, Negated: negated, FoldCase: foldCase}
+}
+
+func (csn *charSetNode) String() string {
+	return fmt.Sprintf("charSetNode(chars='%c', ranges='%c', classes='%v', negated='%v', foldCase='%v')", csn.Chars, csn.Ranges, csn.Classes, csn.Negated, csn.FoldCase)
+}
+
+func (csn *charSetNode) Children() []regexNode {
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------
+
+// posixClassNode is a named class like [:alpha:]. It only appears inside a charSetNode.
+type posixClassNode struct {
+	Name    string
+	Negated bool // written as [:^name:]
+}
+
+func newPosixClassNode(name string, negated bool) *posixClassNode {
+	return &posixClassNode{Name: name, Negated: negated}
+}
+
+func (pcn *posixClassNode) String() string {
+	return fmt.Sprintf("posixClassNode(name='%s', negated='%v')", pcn.Name, pcn.Negated)
+}
+
+func (pcn *posixClassNode) Children() []regexNode {
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------
+
+// unicodeClassNode is a Unicode general category or script class like \p{Lu}
+// or \p{Greek}, or its negation \P{...}.
+type unicodeClassNode struct {
+	Name    string
+	Table   *unicode.RangeTable
+	Negated bool
+}
+
+func newUnicodeClassNode(name string, table *unicode.RangeTable, negated bool) *unicodeClassNode {
+	return &unicodeClassNode{Name: name, Table: table, Negated: negated}
+}
+
+func (ucn *unicodeClassNode) String() string {
+	return fmt.Sprintf("unicodeClassNode(name='%s', negated='%v')", ucn.Name, ucn.Negated)
+}
+
+func (ucn *unicodeClassNode) Children() []regexNode {
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------
+
+type alternationNode struct {
+	Branches []regexNode
+}
+
+func newAlternationNode(children []regexNode) *alternationNode {
+	return &alternationNode{Branches: children}
+}
+
+func (an *alternationNode) String() string {
+	return fmt.Sprintf("alternationNode(branches='%v'!r)", an.Branches)
+}
+
+func (an *alternationNode) Children() []regexNode {
+	return an.Branches
+}
+
+// ------------------------------------------------------------------------------------------
+
+type anchorNode struct {
+	// s for 'start' and e for 'end' of the line, or of each line with Multiline;
+	// A and z for the start and end of the whole text, and Z for its end before
+	// an optional final '\n'; b for a word boundary and B for a non-boundary.
+	Type      rune
+	Multiline bool
+	Unicode   bool // b and B judge word characters as \w does under --unicode
+}
+
+func newAnchorNode(typ rune, multiline, unicodeWords bool) *anchorNode {
+	return &anchorNode{Type: typ, Multiline: multiline, Unicode: unicodeWords}
+}
+
+func (an *anchorNode) String() string {
+	return fmt.Sprintf("anchorNode(type='%c', multiline='%v')", an.Type, an.Multiline)
+}
+
+func (an *anchorNode) Children() []regexNode {
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------
+
+// unboundedRepeat is the Max of a quantifierNode that has no upper limit.
+const unboundedRepeat = -1
+
+type quantifierNode struct {
+	NodeChildren regexNode
+	Type         string
+	Greed        bool
+	Min          int
+	Max          int  // unboundedRepeat when there is no upper limit
+	Possessive   bool // never give back what the greedy expansion took
+}
+
+func newQuantifierNode(children regexNode, typ string, isGreedy bool) *quantifierNode {
+	qn := &quantifierNode{NodeChildren: children, Type: typ, Greed: isGreedy}
+	switch typ {
+	case "ZERO_OR_ONE":
+		qn.Min, qn.Max = 0, 1
+	case "ZERO_OR_MORE":
+		qn.Min, qn.Max = 0, unboundedRepeat
+	case "ONE_OR_MORE":
+		qn.Min, qn.Max = 1, unboundedRepeat
+	}
+	return qn
+}
+
+// newBoundedQuantifierNode builds a counted repetition such as {3}, {2,} or {2,5}.
+func newBoundedQuantifierNode(children regexNode, minCount, maxCount int, isGreedy bool) *quantifierNode {
+	return &quantifierNode{NodeChildren: children, Type: "BOUNDED", Greed: isGreedy, Min: minCount, Max: maxCount}
+}
+
+func (qn *quantifierNode) String() string {
+	return fmt.Sprintf("quantifierNode(child='%v', type='%s', min=%d, max=%d, greedy='%v', possessive='%v')", qn.NodeChildren, qn.Type, qn.Min, qn.Max, qn.Greed, qn.Possessive)
+}
+
+func (qn *quantifierNode) Children() []regexNode {
+	return []regexNode{qn.NodeChildren}
+}
+
+// ------------------------------------------------------------------------------------------
+
+type captureGroupNode struct {
+	Child regexNode
+	Index int
+	Name  string // empty for an unnamed group
+}
+
+func newCaptureGroupNode(child regexNode, ind int, name string) *captureGroupNode {
+	return &captureGroupNode{Child: child, Index: ind, Name: name}
+}
+
+func (cgn *captureGroupNode) String() string {
+	return fmt.Sprintf("captureGroupNode(index='%d', name='%s', child='%v')", cgn.Index, cgn.Name, cgn.Child)
+}
+
+func (cgn *captureGroupNode) Children() []regexNode {
+	return []regexNode{cgn.Child}
+}
+
+// ------------------------------------------------------------------------------------------
+
+// groupNode is a non-capturing group, written (?:...).
+type groupNode struct {
+	Child regexNode
+}
+
+func newGroupNode(child regexNode) *groupNode {
+	return &groupNode{Child: child}
+}
+
+func (gn *groupNode) String() string {
+	return fmt.Sprintf("groupNode(child='%v')", gn.Child)
+}
+
+func (gn *groupNode) Children() []regexNode {
+	return []regexNode{gn.Child}
+}
+
+// ------------------------------------------------------------------------------------------
+
+// atomicGroupNode is an atomic group, written (?>...). Once its child has
+// matched, the match is never revisited to try other ways of matching it.
+type atomicGroupNode struct {
+	Child regexNode
+}
+
+func newAtomicGroupNode(child regexNode) *atomicGroupNode {
+	return &atomicGroupNode{Child: child}
+}
+
+func (agn *atomicGroupNode) String() string {
+	return fmt.Sprintf("atomicGroupNode(child='%v')", agn.Child)
+}
+
+func (agn *atomicGroupNode) Children() []regexNode {
+	return []regexNode{agn.Child}
+}
+
+// ------------------------------------------------------------------------------------------
+
+// lookaroundNode is a zero-width assertion that its child matches, or with
+// Negated that it doesn't, right after (lookahead) or right before (lookbehind)
+// the current position.
+type lookaroundNode struct {
+	Child   regexNode
+	Ahead   bool
+	Negated bool
+	MaxLen  int // longest match of Child in runes, used to bound a lookbehind
+}
+
+func newLookaroundNode(child regexNode, ahead, negated bool, maxLen int) *lookaroundNode {
+	return &lookaroundNode{Child: child, Ahead: ahead, Negated: negated, MaxLen: maxLen}
+}
+
+func (ln *lookaroundNode) String() string {
+	return fmt.Sprintf("lookaroundNode(ahead='%v', negated='%v', child='%v')", ln.Ahead, ln.Negated, ln.Child)
+}
+
+func (ln *lookaroundNode) Children() []regexNode {
+	return []regexNode{ln.Child}
+}
+
+// ------------------------------------------------------------------------------------------
+
+type backreferenceNode struct {
+	Index    int
+	FoldCase bool // compare against the captured text ignoring case
+}
+
+func newBackreferenceNode(idx int, foldCase bool) *backreferenceNode {
+	return &backreferenceNode{Index: idx, FoldCase: foldCase}
+}
+
+func (bn *backreferenceNode) String() string {
+	return fmt.Sprintf("backreferenceNode(index=%d, foldCase='%v')", bn.Index, bn.FoldCase)
+}
+
+func (bn *backreferenceNode) Children() []regexNode {
+	return nil
+}
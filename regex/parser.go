@@ -0,0 +1,898 @@
+package regex
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// Flags change how a pattern is parsed and matched.
+type Flags uint8
+
+const (
+	// FlagUnicodeClasses makes \d, \w and \s follow Unicode categories instead of ASCII.
+	FlagUnicodeClasses Flags = 1 << iota
+	// FlagFoldCase makes literals, sets and backreferences ignore case.
+	FlagFoldCase
+	// FlagDotAll lets '.' match '\n'.
+	FlagDotAll
+	// FlagMultiline makes '^' and '$' match at every line break.
+	FlagMultiline
+	// FlagExtended ignores whitespace and '#' comments in the pattern.
+	FlagExtended
+	// FlagLongest picks the leftmost-longest match, as POSIX tools do, instead
+	// of the first one a Perl-style matcher prefers.
+	FlagLongest
+	// FlagSmartCase sets FlagFoldCase for a pattern without upper-case literals.
+	FlagSmartCase
+)
+
+// inlineFlags maps the letters allowed in '(?imsx-imsx)' to their flags.
+var inlineFlags = map[rune]Flags{
+	'i': FlagFoldCase,
+	's': FlagDotAll,
+	'm': FlagMultiline,
+	'x': FlagExtended,
+}
+
+// parser holds the state of the parsing process. Everything needed to match
+// once parsing is done is copied into the Regexp.
+type parser struct {
+	pattern    []rune
+	position   int
+	groupCount int
+	flags      Flags
+	groupNames map[string]int // name of each named group to its index
+}
+
+// newParser returns a parser positioned at the start of pattern.
+// We use a slice of runes for the pattern to handle Unicode characters correctly.
+func newParser(pattern string, flags Flags) *parser {
+	return &parser{
+		pattern:  []rune(pattern),
+		position: 0,
+		flags:    flags,
+		// groupCount is automatically initialized to 0
+		groupNames: make(map[string]int),
+	}
+}
+
+// subexpNames returns the name of every capture group, indexed by group number.
+// Index 0 stands for the whole match and unnamed groups have an empty name.
+func (rp *parser) subexpNames() []string {
+	names := make([]string, rp.groupCount+1)
+	for name, idx := range rp.groupNames {
+		names[idx] = name
+	}
+	return names
+}
+
+// foldCase reports whether nodes built now should ignore case.
+func (rp *parser) foldCase() bool {
+	return rp.flags&FlagFoldCase != 0
+}
+
+// skipExtended skips whitespace and '#' comments when FlagExtended is set.
+func (rp *parser) skipExtended() {
+	if rp.flags&FlagExtended == 0 {
+		return
+	}
+	for rp.position < len(rp.pattern) {
+		char := rp.peek()
+		if char == '#' {
+			for rp.position < len(rp.pattern) && rp.peek() != '\n' {
+				rp.advance()
+			}
+		} else if !unicode.IsSpace(char) {
+			return
+		}
+		rp.advance()
+	}
+}
+
+// peek returns the rune at the current position without consuming it.
+// It returns the zero value for rune (0) if we are at the end of the pattern.
+func (rp *parser) peek() rune {
+	if rp.position < len(rp.pattern) {
+		return rp.pattern[rp.position]
+	}
+	return 0
+}
+
+// advance consumes the current rune and moves the position forward.
+func (rp *parser) advance() {
+	if rp.position < len(rp.pattern) {
+		rp.position++
+	}
+}
+
+// expect checks if the current rune matches the expected one.
+// If it matches, it consumes the rune and returns nil.
+// If it doesn't match, it returns a ParseError of the given kind.
+func (rp *parser) expect(expectedRune rune, kind ErrorKind) error {
+	// Check what is at the current position
+	peekedRune := rp.peek()
+
+	// If it is not what we expect, return a descriptive error
+	if peekedRune == 0 {
+		return rp.errorAt(rp.position, kind, fmt.Sprintf("expected '%c' but the pattern ended", expectedRune))
+	}
+	if peekedRune != expectedRune {
+		return rp.errorAt(rp.position, kind, fmt.Sprintf("expected '%c' but found '%c'", expectedRune, peekedRune))
+	}
+
+	// If it matches, consume the rune and move on
+	rp.advance()
+	return nil
+}
+
+// parseEscapeSeq parses an escape sequence like '\d', '\W' or a backreference like '\1'
+func (rp *parser) parseEscapeSeq() (regexNode, error) {
+	if expectErr := rp.expect('\\', ErrTrailingBackslash); expectErr != nil {
+		return nil, expectErr
+	}
+
+	// See what character is being escaped
+	escapedChar := rp.peek()
+	if escapedChar == 0 {
+		return nil, rp.errorAt(rp.position-1, ErrTrailingBackslash, "")
+	}
+
+	if escapedChar == 'k' {
+		rp.advance()
+		return rp.parseNamedBackreference()
+	}
+	if escapedChar == 'g' {
+		rp.advance()
+		return rp.parseGBackreference()
+	}
+
+	if escapedChar >= '1' && escapedChar <= '9' {
+		return rp.parseNumberedBackreference()
+	}
+
+	// We have processed this character, so consume it
+	rp.advance()
+
+	switch escapedChar {
+	case 'd', 'w', 's', 'D', 'W', 'S':
+		return newCharClassNode(escapedChar, rp.flags&FlagUnicodeClasses != 0), nil
+	case 'b', 'B':
+		return newAnchorNode(escapedChar, false, rp.flags&FlagUnicodeClasses != 0), nil
+	case 'A', 'z', 'Z':
+		return newAnchorNode(escapedChar, false, false), nil
+	case 'p', 'P':
+		return rp.parseUnicodeClass(escapedChar == 'P')
+	default:
+		return newLiteralNode(escapedChar, rp.foldCase()), nil
+	}
+}
+
+// parseCharSet parses a bracket expression like '[abc]', '[^a-z]' or '[\d_]'.
+// A ']' right after the opening bracket and a '-' at either end are literals.
+func (rp *parser) parseCharSet() (regexNode, error) {
+	start := rp.position
+	rp.advance()
+	negated := false
+	if rp.peek() == '^' {
+		rp.advance()
+		negated = true
+	}
+	set := make(map[rune]struct{})
+	chars := []rune{}
+	var ranges []runeRange
+	var classes []regexNode
+	first := true
+	for {
+		if rp.position >= len(rp.pattern) {
+			return nil, rp.errorAt(start, ErrUnterminatedSet, "")
+		}
+		if rp.peek() == ']' && !first {
+			break
+		}
+		first = false
+
+		lo, class, err := rp.parseSetItem()
+		if err != nil {
+			return nil, err
+		}
+		if class != nil {
+			classes = append(classes, class)
+			continue
+		}
+
+		// A '-' between two members makes a range; before the closing ']' it's a literal.
+		if rp.peek() == '-' && rp.position+1 < len(rp.pattern) && rp.pattern[rp.position+1] != ']' {
+			rangePos := rp.position
+			rp.advance()
+			hi, hiClass, err := rp.parseSetItem()
+			if err != nil {
+				return nil, err
+			}
+			if hiClass != nil {
+				return nil, rp.errorAt(rangePos+1, ErrInvalidRange, "a class can't end a range")
+			}
+			if hi < lo {
+				return nil, rp.errorAt(rangePos-1, ErrInvalidRange, fmt.Sprintf("%c-%c starts after it ends", lo, hi))
+			}
+			ranges = append(ranges, runeRange{Lo: lo, Hi: hi})
+			continue
+		}
+
+		if _, exist := set[lo]; !exist {
+			set[lo] = struct{}{}
+			chars = append(chars, lo)
+		}
+	}
+	if err := rp.expect(']', ErrUnterminatedSet); err != nil {
+		return nil, err
+	}
+
+	return newCharSetNode(chars, ranges, classes, negated, rp.foldCase()), nil
+}
+
+// parseSetItem consumes a single member of a bracket expression.
+// It returns either a rune or, for class escapes like '\d' and POSIX classes
+// like '[:alpha:]', a class node.
+func (rp *parser) parseSetItem() (rune, regexNode, error) {
+	char := rp.peek()
+	if char == '[' && rp.position+1 < len(rp.pattern) && rp.pattern[rp.position+1] == ':' {
+		return rp.parsePosixClass()
+	}
+	rp.advance()
+	if char != '\\' {
+		return char, nil, nil
+	}
+
+	escapedChar := rp.peek()
+	if escapedChar == 0 {
+		return 0, nil, rp.errorAt(rp.position-1, ErrTrailingBackslash, "")
+	}
+	rp.advance()
+	switch escapedChar {
+	case 'd', 'w', 's', 'D', 'W', 'S':
+		return 0, newCharClassNode(escapedChar, rp.flags&FlagUnicodeClasses != 0), nil
+	case 'p', 'P':
+		class, err := rp.parseUnicodeClass(escapedChar == 'P')
+		return 0, class, err
+	default:
+		// Any other escaped rune, including ']', '\\', '^' and '-', stands for itself.
+		return escapedChar, nil, nil
+	}
+}
+
+// parsePosixClass parses a named class such as '[:digit:]' or '[:^space:]'.
+func (rp *parser) parsePosixClass() (rune, regexNode, error) {
+	start := rp.position
+	rp.position += 2 // consume '[:'
+	negated := false
+	if rp.peek() == '^' {
+		rp.advance()
+		negated = true
+	}
+
+	nameStart := rp.position
+	for rp.position < len(rp.pattern) && rp.peek() != ':' {
+		rp.advance()
+	}
+	name := string(rp.pattern[nameStart:rp.position])
+	if rp.position+1 >= len(rp.pattern) || rp.pattern[rp.position+1] != ']' {
+		return 0, nil, rp.errorAt(start, ErrInvalidPosixClass, "missing ':]'")
+	}
+	rp.position += 2 // consume ':]'
+
+	if _, ok := posixClasses[name]; !ok {
+		return 0, nil, rp.errorAt(start, ErrInvalidPosixClass, fmt.Sprintf("unknown class [:%s:]", name))
+	}
+	return 0, newPosixClassNode(name, negated), nil
+}
+
+// parseUnicodeClass parses the name after '\p' or '\P', either a single
+// letter like 'L' or a braced name like '{Lu}', '{Greek}' or '{^Greek}'.
+// Names are looked up among the general categories and then the scripts.
+func (rp *parser) parseUnicodeClass(negated bool) (regexNode, error) {
+	start := rp.position - 2 // the '\p' or '\P'
+	var name string
+	if rp.peek() == '{' {
+		rp.advance()
+		if rp.peek() == '^' {
+			rp.advance()
+			negated = !negated
+		}
+		nameStart := rp.position
+		for rp.position < len(rp.pattern) && rp.peek() != '}' {
+			rp.advance()
+		}
+		name = string(rp.pattern[nameStart:rp.position])
+		if err := rp.expect('}', ErrInvalidUnicodeClass); err != nil {
+			return nil, err
+		}
+	} else {
+		if rp.peek() == 0 {
+			return nil, rp.errorAt(start, ErrInvalidUnicodeClass, "missing class name")
+		}
+		name = string(rp.peek())
+		rp.advance()
+	}
+
+	if table, ok := unicode.Categories[name]; ok {
+		return newUnicodeClassNode(name, table, negated), nil
+	}
+	if table, ok := unicode.Scripts[name]; ok {
+		return newUnicodeClassNode(name, table, negated), nil
+	}
+	return nil, rp.errorAt(start, ErrInvalidUnicodeClass, fmt.Sprintf("unknown class %q", name))
+}
+
+// parseNumberedBackreference parses the digits of a backreference like '\1' or '\12'.
+// Digits are taken for as long as they name a group that has been opened, so
+// with fewer than ten groups '\10' is '\1' followed by a literal '0'.
+func (rp *parser) parseNumberedBackreference() (regexNode, error) {
+	start := rp.position
+	index := 0
+	end := start
+	for i := start; i < len(rp.pattern) && rp.pattern[i] >= '0' && rp.pattern[i] <= '9'; i++ {
+		n := index*10 + int(rp.pattern[i]-'0')
+		if n > rp.groupCount {
+			break
+		}
+		index, end = n, i+1
+	}
+	if end == start {
+		return nil, rp.errorAt(start-1, ErrInvalidBackref, fmt.Sprintf("group %c is not defined", rp.pattern[start]))
+	}
+	rp.position = end
+	return newBackreferenceNode(index, rp.foldCase()), nil
+}
+
+// parseGBackreference parses what follows '\g': an absolute '{N}' or 'N',
+// a relative '{-N}' or '-N' counting back from the last opened group, or '{name}'.
+func (rp *parser) parseGBackreference() (regexNode, error) {
+	start := rp.position - 2
+	braced := rp.peek() == '{'
+	if braced {
+		rp.advance()
+		if char := rp.peek(); char != '-' && (char < '0' || char > '9') {
+			// '\g{name}' is the same as '\k<name>'.
+			nameStart := rp.position
+			for rp.position < len(rp.pattern) && rp.peek() != '}' {
+				rp.advance()
+			}
+			name := string(rp.pattern[nameStart:rp.position])
+			if err := rp.expect('}', ErrInvalidBackref); err != nil {
+				return nil, err
+			}
+			idx, ok := rp.groupNames[name]
+			if !ok {
+				return nil, rp.errorAt(start, ErrInvalidBackref, fmt.Sprintf("no group is named %q", name))
+			}
+			return newBackreferenceNode(idx, rp.foldCase()), nil
+		}
+	}
+
+	relative := rp.peek() == '-'
+	if relative {
+		rp.advance()
+	}
+	n, ok := rp.parseNumber()
+	if !ok {
+		return nil, rp.errorAt(start, ErrInvalidBackref, "missing group number after \\g")
+	}
+	if braced {
+		if err := rp.expect('}', ErrInvalidBackref); err != nil {
+			return nil, err
+		}
+	}
+
+	index := n
+	if relative {
+		index = rp.groupCount + 1 - n
+	}
+	if n == 0 || index < 1 || index > rp.groupCount {
+		return nil, rp.errorAt(start, ErrInvalidBackref, "the group is not defined")
+	}
+	return newBackreferenceNode(index, rp.foldCase()), nil
+}
+
+// parseNamedBackreference parses the '<name>' part of '\k<name>'.
+// The name must belong to a group that has already been opened.
+func (rp *parser) parseNamedBackreference() (regexNode, error) {
+	start := rp.position - 2
+	name, err := rp.parseGroupName()
+	if err != nil {
+		return nil, err
+	}
+	idx, ok := rp.groupNames[name]
+	if !ok {
+		return nil, rp.errorAt(start, ErrInvalidBackref, fmt.Sprintf("no group is named %q", name))
+	}
+	return newBackreferenceNode(idx, rp.foldCase()), nil
+}
+
+// parseGroupName parses a group name in angle brackets, like '<year>'.
+// Names start with a letter or '_' and continue with letters, digits or '_'.
+func (rp *parser) parseGroupName() (string, error) {
+	if err := rp.expect('<', ErrInvalidGroupName); err != nil {
+		return "", err
+	}
+	start := rp.position
+	for rp.position < len(rp.pattern) && rp.peek() != '>' {
+		char := rp.peek()
+		isWord := char < 0x80 && isAlphaNumeric(byte(char))
+		if !isWord || (rp.position == start && char >= '0' && char <= '9') {
+			return "", rp.errorAt(rp.position, ErrInvalidGroupName, fmt.Sprintf("%q can't appear here", char))
+		}
+		rp.advance()
+	}
+	name := string(rp.pattern[start:rp.position])
+	if err := rp.expect('>', ErrInvalidGroupName); err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", rp.errorAt(start, ErrInvalidGroupName, "the name is empty")
+	}
+	return name, nil
+}
+
+// lookingAt reports whether the pattern continues with prefix at the current position.
+func (rp *parser) lookingAt(prefix string) bool {
+	i := rp.position
+	for _, r := range prefix {
+		if i >= len(rp.pattern) || rp.pattern[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// parseGroup parses a parenthesized group: a capturing '(...)', a named
+// '(?P<name>...)' or '(?<name>...)', a non-capturing '(?:...)', an atomic '(?>...)', one of
+// the lookaround assertions '(?=...)', '(?!...)', '(?<=...)' and '(?<!...)',
+// or a flag group '(?i)' or '(?i-s:...)'. A flag group without a body returns
+// a nil node and changes the flags until the end of the enclosing group.
+func (rp *parser) parseGroup() (regexNode, error) {
+	start := rp.position
+	if err := rp.expect('(', ErrInvalidGroup); err != nil {
+		return nil, err
+	}
+	if rp.peek() != '?' {
+		return rp.parseCaptureGroup(start, "")
+	}
+	rp.advance()
+
+	switch {
+	case rp.lookingAt("=") || rp.lookingAt("!"):
+		negated := rp.peek() == '!'
+		rp.advance()
+		child, err := rp.parseGroupBody(start)
+		if err != nil {
+			return nil, err
+		}
+		return newLookaroundNode(child, true, negated, 0), nil
+	case rp.lookingAt("<=") || rp.lookingAt("<!"):
+		negated := rp.pattern[rp.position+1] == '!'
+		rp.position += 2
+		child, err := rp.parseGroupBody(start)
+		if err != nil {
+			return nil, err
+		}
+		_, maxLen, bounded := nodeWidth(child)
+		if !bounded {
+			return nil, rp.errorAt(start, ErrUnboundedLookbehind, "")
+		}
+		return newLookaroundNode(child, false, negated, maxLen), nil
+	case rp.lookingAt(">"):
+		rp.advance()
+		child, err := rp.parseGroupBody(start)
+		if err != nil {
+			return nil, err
+		}
+		return newAtomicGroupNode(child), nil
+	case rp.lookingAt("P<") || rp.lookingAt("<"):
+		if rp.peek() == 'P' {
+			rp.advance()
+		}
+		namePos := rp.position
+		name, err := rp.parseGroupName()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := rp.groupNames[name]; exists {
+			return nil, rp.errorAt(namePos, ErrDuplicateGroupName, fmt.Sprintf("%q is already used", name))
+		}
+		return rp.parseCaptureGroup(start, name)
+	}
+
+	outerFlags := rp.flags
+	if _, isFlag := inlineFlags[rp.peek()]; isFlag || rp.peek() == '-' {
+		flags, err := rp.parseFlags()
+		if err != nil {
+			return nil, err
+		}
+		if rp.peek() == ')' {
+			rp.advance()
+			rp.flags = flags
+			return nil, nil
+		}
+		rp.flags = flags
+	}
+
+	if err := rp.expect(':', ErrInvalidGroup); err != nil {
+		return nil, err
+	}
+	child, err := rp.parseGroupBody(start)
+	rp.flags = outerFlags
+	if err != nil {
+		return nil, err
+	}
+	return newGroupNode(child), nil
+}
+
+// parseFlags parses the 'imsx-imsx' part of a flag group and returns the
+// current flags with those changes applied.
+func (rp *parser) parseFlags() (Flags, error) {
+	flags := rp.flags
+	negate := false
+	for rp.peek() != ':' && rp.peek() != ')' {
+		char := rp.peek()
+		if char == 0 {
+			return 0, rp.errorAt(rp.position, ErrInvalidFlag, "the pattern ended inside the group")
+		}
+		if char == '-' {
+			if negate {
+				return 0, rp.errorAt(rp.position, ErrInvalidFlag, "repeated '-'")
+			}
+			negate = true
+			rp.advance()
+			continue
+		}
+		flag, ok := inlineFlags[char]
+		if !ok {
+			return 0, rp.errorAt(rp.position, ErrInvalidFlag, fmt.Sprintf("unknown flag %q", char))
+		}
+		if negate {
+			flags &^= flag
+		} else {
+			flags |= flag
+		}
+		rp.advance()
+	}
+	return flags, nil
+}
+
+// parseGroupBody parses the alternation inside a group and its closing ')'.
+// start is where the group opened. Flags set by '(?i)' inside the group stop
+// applying at its end.
+func (rp *parser) parseGroupBody(start int) (regexNode, error) {
+	bodyStart := rp.position
+	outerFlags := rp.flags
+	child, err := rp.parseAlternation()
+	rp.flags = outerFlags
+	if err != nil {
+		return nil, err
+	}
+	if rp.peek() != ')' {
+		return nil, rp.errorAt(start, ErrMissingParen, "")
+	}
+	if rp.position == bodyStart {
+		return nil, rp.errorAt(start, ErrEmptyGroup, "")
+	}
+	rp.advance()
+	return child, nil
+}
+
+// nodeWidth returns the minimum and maximum number of runes a node can match.
+// bounded is false when there is no fixed upper limit, as with '*' or a backreference.
+func nodeWidth(node regexNode) (minLen, maxLen int, bounded bool) {
+	switch n := node.(type) {
+	case nil:
+		return 0, 0, true
+	case *literalNode, *charClassNode, *charSetNode, *dotNode, *unicodeClassNode:
+		return 1, 1, true
+	case *anchorNode, *lookaroundNode:
+		return 0, 0, true
+	case *concatenationNode:
+		bounded = true
+		for _, child := range n.NodeChildren {
+			childMin, childMax, childBounded := nodeWidth(child)
+			minLen += childMin
+			maxLen += childMax
+			bounded = bounded && childBounded
+		}
+		return minLen, maxLen, bounded
+	case *alternationNode:
+		bounded = true
+		for i, branch := range n.Branches {
+			branchMin, branchMax, branchBounded := nodeWidth(branch)
+			if i == 0 || branchMin < minLen {
+				minLen = branchMin
+			}
+			if branchMax > maxLen {
+				maxLen = branchMax
+			}
+			bounded = bounded && branchBounded
+		}
+		return minLen, maxLen, bounded
+	case *captureGroupNode:
+		return nodeWidth(n.Child)
+	case *groupNode:
+		return nodeWidth(n.Child)
+	case *atomicGroupNode:
+		return nodeWidth(n.Child)
+	case *quantifierNode:
+		childMin, childMax, childBounded := nodeWidth(n.NodeChildren)
+		if n.Max == unboundedRepeat {
+			return childMin * n.Min, 0, childMax == 0 && childBounded
+		}
+		return childMin * n.Min, childMax * n.Max, childBounded
+	}
+	return 0, 0, false
+}
+
+// parseCaptureGroup parses the body of a capture group that opened at start.
+func (rp *parser) parseCaptureGroup(start int, name string) (regexNode, error) {
+	rp.groupCount++
+	groupIdx := rp.groupCount
+	if name != "" {
+		// Registered before the body so that the group can refer to itself.
+		rp.groupNames[name] = groupIdx
+	}
+	child, err := rp.parseGroupBody(start)
+	if err != nil {
+		return nil, err
+	}
+	return newCaptureGroupNode(child, groupIdx, name), nil
+}
+
+func (rp *parser) parseAtom() (regexNode, error) {
+	char := rp.peek()
+	if char == 0 {
+		return nil, nil
+	}
+
+	var atom regexNode
+	var err error
+	if char == '(' {
+		atom, err = rp.parseGroup()
+	} else if char == '[' {
+		atom, err = rp.parseCharSet()
+	} else if char == '\\' {
+		atom, err = rp.parseEscapeSeq()
+	} else if char == '*' || char == '+' || char == '?' || (char == '{' && rp.startsBounds()) {
+		return nil, rp.errorAt(rp.position, ErrMissingRepeatArgument, fmt.Sprintf("'%c' has to follow something it can repeat", char))
+	} else if char == '.' {
+		atom = newDotNode(rp.flags&FlagDotAll != 0)
+		rp.advance()
+	} else if char == '^' {
+		atom = newAnchorNode('s', rp.flags&FlagMultiline != 0, false)
+		rp.advance()
+	} else if char == '$' {
+		atom = newAnchorNode('e', rp.flags&FlagMultiline != 0, false)
+		rp.advance()
+	} else {
+		atom = newLiteralNode(char, rp.foldCase())
+		rp.advance()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if atom == nil {
+		return nil, nil
+	}
+
+	rp.skipExtended()
+	nextChar := rp.peek()
+	if nextChar == '+' || nextChar == '*' || nextChar == '?' {
+		rp.advance()
+		var qType string
+		switch nextChar {
+		case '+':
+			qType = "ONE_OR_MORE"
+		case '*':
+			qType = "ZERO_OR_MORE"
+		case '?':
+			qType = "ZERO_OR_ONE"
+		}
+		return rp.parseQuantifierSuffix(newQuantifierNode(atom, qType, true)), nil
+	}
+	if nextChar == '{' && rp.startsBounds() {
+		minCount, maxCount, err := rp.parseBounds()
+		if err != nil {
+			return nil, err
+		}
+		return rp.parseQuantifierSuffix(newBoundedQuantifierNode(atom, minCount, maxCount, true)), nil
+	}
+
+	return atom, nil
+}
+
+// parseQuantifierSuffix consumes the optional '?' that makes a quantifier lazy
+// or '+' that makes it possessive, and applies it to quant.
+func (rp *parser) parseQuantifierSuffix(quant *quantifierNode) *quantifierNode {
+	switch rp.peek() {
+	case '?':
+		rp.advance()
+		quant.Greed = false
+	case '+':
+		rp.advance()
+		quant.Possessive = true
+	}
+	return quant
+}
+
+// maxRepeatCount caps the counts accepted in a bounded repetition.
+const maxRepeatCount = 1000
+
+// startsBounds reports whether the '{' at the current position opens a counted
+// repetition. A '{' that isn't followed by a digit is treated as a literal.
+func (rp *parser) startsBounds() bool {
+	next := rp.position + 1
+	return next < len(rp.pattern) && rp.pattern[next] >= '0' && rp.pattern[next] <= '9'
+}
+
+// parseNumber consumes a run of decimal digits. It returns false if there were none.
+func (rp *parser) parseNumber() (int, bool) {
+	start := rp.position
+	for rp.peek() >= '0' && rp.peek() <= '9' {
+		rp.advance()
+	}
+	if rp.position == start {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(rp.pattern[start:rp.position]))
+	if err != nil {
+		// Only possible on overflow, which is well past the limit anyway.
+		return maxRepeatCount + 1, true
+	}
+	return n, true
+}
+
+// parseBounds parses the counts of '{n}', '{n,}' or '{n,m}'.
+// A missing upper bound is returned as unboundedRepeat.
+func (rp *parser) parseBounds() (int, int, error) {
+	start := rp.position
+	if err := rp.expect('{', ErrInvalidRepeat); err != nil {
+		return 0, 0, err
+	}
+
+	minCount, ok := rp.parseNumber()
+	if !ok {
+		return 0, 0, rp.errorAt(start, ErrInvalidRepeat, "missing minimum count")
+	}
+	maxCount := minCount
+	if rp.peek() == ',' {
+		rp.advance()
+		if rp.peek() == '}' {
+			maxCount = unboundedRepeat
+		} else if maxCount, ok = rp.parseNumber(); !ok {
+			return 0, 0, rp.errorAt(rp.position, ErrInvalidRepeat, "expected a count or '}' after ','")
+		}
+	}
+	if rp.peek() != '}' {
+		return 0, 0, rp.errorAt(rp.position, ErrInvalidRepeat, "expected '}'")
+	}
+	rp.advance()
+
+	if minCount > maxRepeatCount || maxCount > maxRepeatCount {
+		return 0, 0, rp.errorAt(start, ErrInvalidRepeat, fmt.Sprintf("counts can't exceed %d", maxRepeatCount))
+	}
+	if maxCount != unboundedRepeat && maxCount < minCount {
+		return 0, 0, rp.errorAt(start, ErrInvalidRepeat, fmt.Sprintf("minimum exceeds maximum in {%d,%d}", minCount, maxCount))
+	}
+	return minCount, maxCount, nil
+}
+
+func (rp *parser) parseConcatenation() (regexNode, error) {
+	var nodes []regexNode
+	for {
+		rp.skipExtended()
+		currentChar := rp.peek()
+		if currentChar == 0 || currentChar == '|' || currentChar == ')' {
+			break
+		}
+		atomStart := rp.position
+		atom, err := rp.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		if atom != nil {
+			nodes = append(nodes, atom)
+		} else if rp.position > atomStart {
+			// A flag group like '(?i)' only changes how the rest is parsed.
+			continue
+		} else {
+			// This might happen if _parse_atom consumes a char but returns None (e.g., empty group () is not handled here)
+			// Or if it fails to parse a valid atom, we should stop
+			break
+		}
+	}
+
+	if len(nodes) == 0 {
+		return nil, nil
+	} else if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return newConcatenationNode(nodes), nil
+}
+
+func (rp *parser) parseAlternation() (regexNode, error) {
+	// A | B | C
+	branches := []regexNode{}
+	node, err := rp.parseConcatenation()
+	if err != nil {
+		return nil, err
+	}
+	if node != nil {
+		branches = append(branches, node)
+	} else if rp.peek() == '|' {
+		// This handles a pattern starting with '|' like "|a", or "x(|a)".
+		return nil, rp.errorAt(rp.position, ErrEmptyAlternative, "nothing before '|'")
+	}
+
+	// Loop to see if there are any more alternatives
+	for rp.peek() == '|' {
+		barPos := rp.position
+		rp.advance() // Consume the '|'
+		node, err := rp.parseConcatenation()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			branches = append(branches, node)
+		} else {
+			// This handles a pattern ending in '|' just like "a|", or "a||b".
+			return nil, rp.errorAt(barPos, ErrEmptyAlternative, "nothing after '|'")
+		}
+	}
+
+	if len(branches) == 0 {
+		return nil, nil
+	}
+	if len(branches) == 1 {
+		return branches[0], nil
+	}
+	return newAlternationNode(branches), nil
+}
+
+func (rp *parser) parse() (regexNode, error) {
+	node, err := rp.parseAlternation()
+	if err != nil {
+		return nil, err
+	}
+	if rp.position != len(rp.pattern) {
+		// parseAlternation only stops early at a ')' with no group to close.
+		return nil, rp.errorAt(rp.position, ErrUnmatchedParen, "")
+	}
+	return node, nil
+}
+
+// hasUpperLiteral reports whether any literal or set member in the tree is an
+// upper-case letter. Class escapes like \W or \p{Lu} don't count.
+func hasUpperLiteral(node regexNode) bool {
+	switch n := node.(type) {
+	case nil:
+		return false
+	case *literalNode:
+		return unicode.IsUpper(n.Char)
+	case *charSetNode:
+		for _, char := range n.Chars {
+			if unicode.IsUpper(char) {
+				return true
+			}
+		}
+		for _, rng := range n.Ranges {
+			if unicode.IsUpper(rng.Lo) || unicode.IsUpper(rng.Hi) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, child := range node.Children() {
+		if hasUpperLiteral(child) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,441 @@
+package regex
+
+import "unicode/utf8"
+
+// The Pike VM runs a pattern compiled from the AST in time linear in the
+// length of the input. It steps every candidate thread forward together, one
+// code point at a time, so unlike the backtracker it never tries the same
+// position twice. It can't express backreferences or lookarounds, nor atomic
+// groups and possessive quantifiers beyond a repeated single rune, so patterns
+// that use them stay on the backtracker.
+//
+// In leftmost-longest mode the VM keeps stepping after a match, and when two
+// threads reach the same instruction at the same position it keeps the one
+// whose captures POSIX prefers, since both have the same future.
+
+type instOp uint8
+
+const (
+	instRune   instOp = iota // consume one code point accepted by node
+	instSplit                // continue at x, or failing that at y
+	instJmp                  // continue at x
+	instSave                 // record the current position in capture slot
+	instAssert               // check the zero-width *anchorNode in node
+	instNoRune               // check that node doesn't accept the next code point
+	instMatch                // report a match
+)
+
+type inst struct {
+	op   instOp
+	node regexNode
+	x    int
+	y    int
+	slot int
+}
+
+type program struct {
+	insts    []inst
+	numSlots int
+	longest  bool // find the leftmost-longest match instead of the leftmost-first
+}
+
+// maxProgramSize bounds the instructions a pattern compiles to. Counted
+// repetition copies its child, so '(a{1000}){1000}' would otherwise be huge.
+const maxProgramSize = 10000
+
+// compileProgram compiles the AST for the Pike VM. It returns nil if the
+// pattern uses a construct the VM can't run or is too large to compile.
+func compileProgram(ast regexNode, groupCount int, longest bool) *program {
+	c := &compiler{prog: &program{numSlots: 2 * (groupCount + 1), longest: longest}}
+	c.emit(inst{op: instSave, slot: 0})
+	if !c.compile(ast) {
+		return nil
+	}
+	c.emit(inst{op: instSave, slot: 1})
+	c.emit(inst{op: instMatch})
+	if len(c.prog.insts) > maxProgramSize {
+		return nil
+	}
+	return c.prog
+}
+
+type compiler struct {
+	prog *program
+
+	// runes holds the index of every instRune emitted so far. While inEmpty is
+	// set, each rune is followed by a jump recorded in runeJumps; see compileIteration.
+	runes     []int
+	inEmpty   bool
+	runeJumps []int
+}
+
+// emit appends an instruction and returns its index.
+func (c *compiler) emit(in inst) int {
+	c.prog.insts = append(c.prog.insts, in)
+	return len(c.prog.insts) - 1
+}
+
+// next is the index the next emitted instruction will get.
+func (c *compiler) next() int {
+	return len(c.prog.insts)
+}
+
+// split emits a split that prefers x when greedy and y otherwise. The caller
+// patches the targets once both are known.
+func (c *compiler) split() int {
+	return c.emit(inst{op: instSplit})
+}
+
+// patchSplit points a split at body and exit in the order greedy asks for.
+func (c *compiler) patchSplit(pc, body, exit int, greedy bool) {
+	if greedy {
+		c.prog.insts[pc].x, c.prog.insts[pc].y = body, exit
+	} else {
+		c.prog.insts[pc].x, c.prog.insts[pc].y = exit, body
+	}
+}
+
+// compile emits the instructions for node, which fall through to whatever is
+// emitted next. It returns false if the node can't run on the VM.
+func (c *compiler) compile(node regexNode) bool {
+	if len(c.prog.insts) > maxProgramSize {
+		return false
+	}
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *literalNode, *charClassNode, *charSetNode, *dotNode, *unicodeClassNode:
+		c.runes = append(c.runes, c.emit(inst{op: instRune, node: n}))
+		if c.inEmpty {
+			c.runeJumps = append(c.runeJumps, c.emit(inst{op: instJmp}))
+		}
+		return true
+	case *anchorNode:
+		c.emit(inst{op: instAssert, node: n})
+		return true
+	case *concatenationNode:
+		for _, child := range n.NodeChildren {
+			if !c.compile(child) {
+				return false
+			}
+		}
+		return true
+	case *alternationNode:
+		var jumps []int
+		for i, branch := range n.Branches {
+			if i == len(n.Branches)-1 {
+				if !c.compile(branch) {
+					return false
+				}
+				break
+			}
+			split := c.split()
+			c.prog.insts[split].x = c.next()
+			if !c.compile(branch) {
+				return false
+			}
+			jumps = append(jumps, c.emit(inst{op: instJmp}))
+			c.prog.insts[split].y = c.next()
+		}
+		for _, jmp := range jumps {
+			c.prog.insts[jmp].x = c.next()
+		}
+		return true
+	case *captureGroupNode:
+		c.emit(inst{op: instSave, slot: 2 * n.Index})
+		if !c.compile(n.Child) {
+			return false
+		}
+		c.emit(inst{op: instSave, slot: 2*n.Index + 1})
+		return true
+	case *groupNode:
+		return c.compile(n.Child)
+	case *quantifierNode:
+		if n.Possessive && !isSingleRune(n.NodeChildren) {
+			return false
+		}
+		return c.compileRepeat(n, n.Possessive)
+	case *atomicGroupNode:
+		// A single rune can only match one way, and a repeated one behaves like
+		// a possessive quantifier once it can't give anything back.
+		if isSingleRune(n.Child) {
+			return c.compile(n.Child)
+		}
+		body, ok := n.Child.(*quantifierNode)
+		if !ok || !isSingleRune(body.NodeChildren) {
+			return false
+		}
+		if !body.Greed {
+			// The first match of a lazy repeat is its minimum.
+			for i := 0; i < body.Min; i++ {
+				if !c.compile(body.NodeChildren) {
+					return false
+				}
+			}
+			return true
+		}
+		return c.compileRepeat(body, true)
+	}
+	// Backreferences, lookarounds and other atomic groups need the backtracker.
+	return false
+}
+
+// isSingleRune reports whether node consumes exactly one code point.
+func isSingleRune(node regexNode) bool {
+	switch node.(type) {
+	case *literalNode, *charClassNode, *charSetNode, *dotNode, *unicodeClassNode:
+		return true
+	}
+	return false
+}
+
+// compileRepeat emits Min copies of the child followed by either a loop, when
+// there is no upper limit, or Max-Min nested optional copies. With possessive,
+// which needs a single-rune child, the repeat may only stop short of Max
+// where the next rune couldn't have been taken, so it never gives any back.
+//
+// When the child can match empty, an iteration from the last required one on
+// that consumes nothing leaves the repeat, the rule matchRepeat follows.
+func (c *compiler) compileRepeat(n *quantifierNode, possessive bool) bool {
+	minLen, _, _ := nodeWidth(n.NodeChildren)
+	// Jumps taken after an empty iteration, patched once the end is known.
+	var emptyExits []int
+	iteration := func(last bool) bool {
+		if minLen > 0 || last {
+			// Leaving after the final iteration is all an empty one could do.
+			return c.compile(n.NodeChildren)
+		}
+		exit, ok := c.compileIteration(n.NodeChildren)
+		emptyExits = append(emptyExits, exit)
+		return ok
+	}
+
+	for i := 0; i < n.Min; i++ {
+		if !iteration(i < n.Min-1 || n.Max == n.Min) {
+			return false
+		}
+	}
+
+	if n.Max == unboundedRepeat {
+		loop := c.split()
+		if !iteration(false) {
+			return false
+		}
+		c.emit(inst{op: instJmp, x: loop})
+		c.patchSplit(loop, loop+1, c.next(), n.Greed)
+		if possessive {
+			c.emit(inst{op: instNoRune, node: n.NodeChildren})
+		}
+		for _, jmp := range emptyExits {
+			c.prog.insts[jmp].x = c.next()
+		}
+		return true
+	}
+
+	var splits []int
+	for i := n.Min; i < n.Max; i++ {
+		splits = append(splits, c.split())
+		if !iteration(i == n.Max-1) {
+			return false
+		}
+	}
+	exit := c.next()
+	if possessive && len(splits) > 0 {
+		full := c.emit(inst{op: instJmp})
+		exit = c.emit(inst{op: instNoRune, node: n.NodeChildren})
+		c.prog.insts[full].x = c.next()
+	}
+	for _, split := range splits {
+		c.patchSplit(split, split+1, exit, n.Greed)
+	}
+	for _, jmp := range emptyExits {
+		c.prog.insts[jmp].x = exit
+	}
+	return true
+}
+
+// compileIteration emits one iteration of a repeat whose child can match
+// empty, in two copies so that a thread's pc tells whether the iteration has
+// consumed anything yet. The first copy runs until a rune is taken, then
+// jumps to the same place in the second; reaching the end of the first means
+// the iteration was empty, and the returned jump, for the caller to patch,
+// leaves the repeat. The second copy falls through to the next iteration.
+//
+// Inside the first copy of an enclosing iteration, a rune already jumps to
+// that iteration's second copy, which holds both copies of this one, so only
+// the outermost first copy records its rune jumps.
+func (c *compiler) compileIteration(child regexNode) (int, bool) {
+	outermost := !c.inEmpty
+	jumpsFrom := len(c.runeJumps)
+	c.inEmpty = true
+	if !c.compile(child) {
+		return 0, false
+	}
+	c.inEmpty = !outermost
+	exit := c.emit(inst{op: instJmp})
+
+	runesFrom := len(c.runes)
+	if !c.compile(child) {
+		return 0, false
+	}
+	if outermost {
+		// Both copies emit the same runes in the same order.
+		for i, jmp := range c.runeJumps[jumpsFrom:] {
+			c.prog.insts[jmp].x = c.runes[runesFrom+i] + 1
+		}
+		c.runeJumps = c.runeJumps[:jumpsFrom]
+	}
+	return exit, true
+}
+
+type thread struct {
+	pc   int
+	caps []int
+}
+
+// threadQueue is an ordered set of threads keyed by pc. Earlier threads have
+// higher priority. The sparse/dense pair makes membership tests and clearing O(1).
+type threadQueue struct {
+	sparse []int
+	dense  []thread
+}
+
+func newThreadQueue(size int) *threadQueue {
+	return &threadQueue{sparse: make([]int, size), dense: make([]thread, 0, size)}
+}
+
+func (q *threadQueue) contains(pc int) bool {
+	i := q.sparse[pc]
+	return i < len(q.dense) && q.dense[i].pc == pc
+}
+
+func (q *threadQueue) add(pc int, caps []int) {
+	q.sparse[pc] = len(q.dense)
+	q.dense = append(q.dense, thread{pc: pc, caps: caps})
+}
+
+func (q *threadQueue) clear() {
+	q.dense = q.dense[:0]
+}
+
+// match finds the leftmost-first match starting at or after from, exactly as
+// the backtracker would, or the leftmost-longest one in longest mode. With
+// anchored, only a match starting at 0 counts.
+// It returns the start and end of the match and its capture slots, or -1, -1.
+func (prog *program) match(inputLine string, from int, anchored bool) (int, int, []int) {
+	if anchored && from != 0 {
+		return -1, -1, nil
+	}
+
+	clist := newThreadQueue(len(prog.insts))
+	nlist := newThreadQueue(len(prog.insts))
+	var matched []int
+
+	for pos := from; ; {
+		// Start a new, lowest-priority thread here until something has matched.
+		if matched == nil && (!anchored || pos == from) {
+			prog.addThread(clist, 0, inputLine, pos, newCaptures(prog.numSlots/2-1))
+		}
+		if len(clist.dense) == 0 {
+			break
+		}
+
+		var r rune
+		size := 0
+		if pos < len(inputLine) {
+			r, size = utf8.DecodeRuneInString(inputLine[pos:])
+		}
+
+	step:
+		for _, t := range clist.dense {
+			in := prog.insts[t.pc]
+			switch in.op {
+			case instMatch:
+				if prog.longest {
+					// A longer match may still come, so keep every thread going.
+					if matched == nil || longestPrefers(t.caps, matched) {
+						matched = t.caps
+					}
+					continue
+				}
+				// Threads after this one have lower priority, so they can't win.
+				matched = t.caps
+				break step
+			case instRune:
+				if prog.longest && matched != nil && t.caps[0] > matched[0] {
+					// It started to the right of a match, so it can't win.
+					continue
+				}
+				if size > 0 && matchesRune(in.node, r) {
+					prog.addThread(nlist, t.pc+1, inputLine, pos+size, t.caps)
+				}
+			}
+		}
+
+		if size == 0 {
+			break
+		}
+		pos += size
+		clist, nlist = nlist, clist
+		nlist.clear()
+	}
+
+	if matched == nil {
+		return -1, -1, nil
+	}
+	return matched[0], matched[1], matched
+}
+
+// addThread adds the thread at pc to q, following jumps, splits, saves and
+// assertions at pos so that q only ends up holding runes and matches to step.
+// A thread already at pc wins, unless in longest mode the new one's captures
+// are preferred; then it takes over and its successors are revisited.
+func (prog *program) addThread(q *threadQueue, pc int, inputLine string, pos int, caps []int) {
+	if !q.contains(pc) {
+		q.add(pc, caps)
+	} else if t := &q.dense[q.sparse[pc]]; prog.longest && longestPrefers(caps, t.caps) {
+		t.caps = caps
+	} else {
+		return
+	}
+
+	in := prog.insts[pc]
+	switch in.op {
+	case instJmp:
+		prog.addThread(q, in.x, inputLine, pos, caps)
+	case instSplit:
+		prog.addThread(q, in.x, inputLine, pos, caps)
+		prog.addThread(q, in.y, inputLine, pos, caps)
+	case instSave:
+		newCaps := make([]int, len(caps))
+		copy(newCaps, caps)
+		newCaps[in.slot] = pos
+		if prog.longest && in.slot%2 == 0 {
+			// Forget where the group ended last time round a loop, so threads
+			// inside the group compare on where it started this time.
+			newCaps[in.slot+1] = -1
+		}
+		prog.addThread(q, pc+1, inputLine, pos, newCaps)
+	case instAssert:
+		if anchorMatches(in.node.(*anchorNode), inputLine, pos) {
+			prog.addThread(q, pc+1, inputLine, pos, caps)
+		}
+	case instNoRune:
+		if pos == len(inputLine) {
+			prog.addThread(q, pc+1, inputLine, pos, caps)
+		} else if r, _ := utf8.DecodeRuneInString(inputLine[pos:]); !matchesRune(in.node, r) {
+			prog.addThread(q, pc+1, inputLine, pos, caps)
+		}
+	}
+}
+
+// longestPrefers reports whether capture slots a describe a better
+// leftmost-longest match than b: one that starts earlier, or else one that
+// posixPrefers. Threads still running haven't set slot 1, so they compare
+// on their captures alone.
+func longestPrefers(a, b []int) bool {
+	if a[0] != b[0] {
+		return a[0] < b[0]
+	}
+	return posixPrefers(MatchResult{EndIdx: a[1], Captures: a}, MatchResult{EndIdx: b[1], Captures: b})
+}
@@ -0,0 +1,138 @@
+package regex
+
+import (
+	"iter"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// randomPattern builds a pattern of up to depth levels of nesting from a small
+// alphabet, mixing the constructs where the two engines could disagree: empty
+// loop bodies, lazy and bounded repeats, alternations and captures.
+func randomPattern(rng *rand.Rand, depth int) string {
+	if depth == 0 || rng.Intn(4) == 0 {
+		return []string{"a", "b", "c", ".", "[ab]", `\w`, "a?", "b*?"}[rng.Intn(8)]
+	}
+	switch rng.Intn(5) {
+	case 0:
+		return randomPattern(rng, depth-1) + randomPattern(rng, depth-1)
+	case 1:
+		return randomPattern(rng, depth-1) + "|" + randomPattern(rng, depth-1)
+	case 2:
+		return "(" + randomPattern(rng, depth-1) + ")"
+	case 3:
+		return "(?:" + randomPattern(rng, depth-1) + ")"
+	}
+	quantifiers := []string{"*", "+", "?", "*?", "+?", "??", "{2}", "{0,2}", "{1,3}?", "{2,}"}
+	return "(?:" + randomPattern(rng, depth-1) + ")" + quantifiers[rng.Intn(len(quantifiers))]
+}
+
+// backtrackFind is the search of Regexp.find, run on the backtracker even
+// when the pattern compiles for the VM. pick is firstMatch or longestMatch.
+func backtrackFind(re *Regexp, s string, pick func(iter.Seq[MatchResult]) (MatchResult, bool)) []int {
+	for pos := 0; pos <= len(s); pos++ {
+		res, ok := pick(matchPossibilities(re.ast, s, pos, newCaptures(re.numSubexp), newStepBudget(0)))
+		if ok {
+			caps := res.Captures
+			caps[0], caps[1] = res.StartIdx, res.EndIdx
+			return caps
+		}
+	}
+	return nil
+}
+
+// TestPikeVMMatchesBacktracker runs random patterns through both engines and
+// checks that they agree on the match and on every capture.
+func TestPikeVMMatchesBacktracker(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		expr := randomPattern(rng, 4)
+		re, err := Compile(expr, 0)
+		if err != nil || re.prog == nil {
+			continue
+		}
+		for j := 0; j < 6; j++ {
+			var input strings.Builder
+			for k := rng.Intn(7); k > 0; k-- {
+				input.WriteByte("abc"[rng.Intn(3)])
+			}
+			s := input.String()
+			_, _, got := re.prog.match(s, 0, false)
+			if want := backtrackFind(re, s, firstMatch); !reflect.DeepEqual(got, want) {
+				t.Errorf("%q on %q: Pike VM gives %v, backtracker gives %v", expr, s, got, want)
+			}
+		}
+	}
+}
+
+// groupInRepeat reports whether a capture group sits inside a repeat, where
+// a later iteration can overwrite what an earlier one captured.
+func groupInRepeat(node regexNode, inRepeat bool) bool {
+	switch node.(type) {
+	case *captureGroupNode:
+		if inRepeat {
+			return true
+		}
+	case *quantifierNode:
+		inRepeat = true
+	}
+	for _, child := range node.Children() {
+		if child != nil && groupInRepeat(child, inRepeat) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestPikeVMLongestMatchesBacktracker checks leftmost-longest mode the same
+// way. The VM has to pick between threads before it knows whether a later
+// iteration will overwrite a group, so captures are only compared for groups
+// outside repeats.
+func TestPikeVMLongestMatchesBacktracker(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		expr := randomPattern(rng, 3)
+		re, err := Compile(expr, FlagLongest)
+		if err != nil || re.prog == nil {
+			continue
+		}
+		for j := 0; j < 6; j++ {
+			var input strings.Builder
+			for k := rng.Intn(7); k > 0; k-- {
+				input.WriteByte("abc"[rng.Intn(3)])
+			}
+			s := input.String()
+			_, _, got := re.prog.match(s, 0, false)
+			want := backtrackFind(re, s, longestMatch)
+			if (got == nil) != (want == nil) || got != nil && (got[0] != want[0] || got[1] != want[1]) {
+				t.Errorf("%q on %q: Pike VM gives %v, backtracker gives %v", expr, s, got, want)
+			} else if !groupInRepeat(re.ast, false) && !reflect.DeepEqual(got, want) {
+				t.Errorf("%q on %q: Pike VM captures %v, backtracker captures %v", expr, s, got, want)
+			}
+		}
+	}
+}
+
+func TestEmptyIterations(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    []int
+	}{
+		// An iteration that matches empty ends the loop, as in Perl.
+		{`c(?:b*?)+`, "cbbb", []int{0, 1}},
+		{`c(?:b*?)+(?=x?)`, "cbbb", []int{0, 1}},
+		{`(?:(.)*?)*`, "ab", []int{0, 0, -1, -1}},
+		{`((a|a)*?)*`, "aa", []int{0, 0, 0, 0, -1, -1}},
+		{`(a?)*b`, "aab", []int{0, 3, 2, 2}},
+		{`(?:a?){3}`, "a", []int{0, 1}},
+	}
+	for _, tt := range tests {
+		re := MustCompile(tt.pattern, 0)
+		if got := re.FindStringSubmatchIndex(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q.FindStringSubmatchIndex(%q) = %v, want %v", tt.pattern, tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package regex
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// The prefilter rules out lines cheaply before any engine runs. It pulls two
+// things out of the AST: a literal prefix that every match starts with, and
+// literal substrings that every match contains. A line missing one of the
+// substrings can't match, and when there is a prefix the backtracker only
+// needs to try the positions where it occurs.
+//
+// Only case-sensitive literals count, since folded ones can match several
+// spellings. Lookaround bodies are left out because the text they look at
+// isn't part of the match.
+
+type prefilter struct {
+	prefix   string   // every match starts with this
+	required []string // every match contains each of these
+}
+
+// maxExactRepeat bounds the text a counted repetition like 'ab{3}' expands to.
+const maxExactRepeat = 1024
+
+// literalInfo is what analyzeLiterals knows about the matches of one node.
+type literalInfo struct {
+	exact    bool     // every match is exactly text
+	text     string   // the whole match if exact, otherwise a prefix of it
+	required []string // substrings every match contains
+}
+
+// newPrefilter returns the prefilter for ast, or nil if it found no literals.
+func newPrefilter(ast regexNode) *prefilter {
+	info := analyzeLiterals(ast)
+	pf := &prefilter{prefix: info.text}
+	if info.text != "" {
+		pf.required = append(pf.required, info.text)
+	}
+	for _, s := range info.required {
+		if s != "" && !strings.Contains(info.text, s) {
+			pf.required = append(pf.required, s)
+		}
+	}
+	if len(pf.required) == 0 {
+		return nil
+	}
+	return pf
+}
+
+// mayMatch reports whether text contains every required substring. A nil
+// prefilter allows everything.
+func (pf *prefilter) mayMatch(text string) bool {
+	if pf == nil {
+		return true
+	}
+	for _, s := range pf.required {
+		if !strings.Contains(text, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func analyzeLiterals(node regexNode) literalInfo {
+	switch n := node.(type) {
+	case nil:
+		return literalInfo{exact: true}
+	case *literalNode:
+		if n.FoldCase {
+			return literalInfo{}
+		}
+		return literalInfo{exact: true, text: string(n.Char)}
+	case *anchorNode, *lookaroundNode:
+		// Zero-width, so the literals on either side stay adjacent.
+		return literalInfo{exact: true}
+	case *captureGroupNode:
+		return analyzeLiterals(n.Child)
+	case *groupNode:
+		return analyzeLiterals(n.Child)
+	case *atomicGroupNode:
+		return analyzeLiterals(n.Child)
+	case *concatenationNode:
+		return analyzeConcatenation(n.NodeChildren)
+	case *alternationNode:
+		// Only a prefix shared by every branch survives.
+		var info literalInfo
+		for i, branch := range n.Branches {
+			text := analyzeLiterals(branch).text
+			if i == 0 {
+				info.text = text
+				continue
+			}
+			info.text = commonPrefix(info.text, text)
+		}
+		return info
+	case *quantifierNode:
+		if n.Min == 0 {
+			return literalInfo{}
+		}
+		child := analyzeLiterals(n.NodeChildren)
+		info := literalInfo{text: child.text, required: child.required}
+		if child.exact && n.Min == n.Max && len(child.text)*n.Min <= maxExactRepeat {
+			info.exact = true
+			info.text = strings.Repeat(child.text, n.Min)
+		}
+		return info
+	}
+	// Classes, sets, dots and backreferences match text we can't predict.
+	return literalInfo{}
+}
+
+// analyzeConcatenation joins the literal text of consecutive exact children
+// into runs. Each run, extended by the prefix of the child that ends it, is a
+// required substring.
+func analyzeConcatenation(children []regexNode) literalInfo {
+	info := literalInfo{exact: true}
+	var run strings.Builder
+	for _, child := range children {
+		c := analyzeLiterals(child)
+		if c.exact {
+			run.WriteString(c.text)
+			continue
+		}
+		if info.exact {
+			info.exact = false
+			info.text = run.String() + c.text
+		}
+		info.required = append(info.required, run.String()+c.text)
+		info.required = append(info.required, c.required...)
+		run.Reset()
+	}
+	if info.exact {
+		info.text = run.String()
+	} else {
+		info.required = append(info.required, run.String())
+	}
+	return info
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	// Don't split a multi-byte character.
+	for i > 0 && i < len(a) && !utf8.RuneStart(a[i]) {
+		i--
+	}
+	return a[:i]
+}
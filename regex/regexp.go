@@ -0,0 +1,397 @@
+// Package regex implements the regular expressions behind mygrep: a parser
+// for Perl-style patterns and three engines that run them. A lazy DFA answers
+// whether a line matches at all, a Pike VM finds matches in linear time, and
+// a backtracker handles what the other two can't, such as backreferences and
+// lookarounds.
+//
+// A Regexp is safe for concurrent use by multiple goroutines, except for
+// SetMaxSteps.
+package regex
+
+import (
+	"iter"
+	"strings"
+	"unicode/utf8"
+)
+
+// Regexp is a compiled regular expression.
+type Regexp struct {
+	expr        string
+	ast         regexNode
+	flags       Flags    // the flags in effect at the end of the pattern
+	numSubexp   int      // number of capture groups
+	subexpNames []string // name of each group, indexed by group number
+	anchored    bool     // every match has to start at the beginning of the text
+	tailWidth   int      // every match ends the text and spans at most this many runes, or -1
+	prog        *program // the pattern compiled for the Pike VM, or nil to backtrack
+	dfa         *lazyDFA // answers whether the text matches at all, or nil if unsupported
+	prefilter   *prefilter
+	maxSteps    int // backtracking steps allowed per search, or 0 for no limit
+}
+
+// Compile parses a pattern and returns a Regexp that matches it.
+// A pattern that fails to parse returns a *ParseError.
+func Compile(expr string, flags Flags) (*Regexp, error) {
+	rp := newParser(expr, flags)
+	ast, err := rp.parse()
+	if err == nil && flags&FlagSmartCase != 0 && flags&FlagFoldCase == 0 && !hasUpperLiteral(ast) {
+		// Smart case: a pattern written all in lower case ignores case.
+		rp = newParser(expr, flags|FlagFoldCase)
+		ast, err = rp.parse()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	re := &Regexp{
+		expr:        expr,
+		ast:         ast,
+		flags:       rp.flags,
+		numSubexp:   rp.groupCount,
+		subexpNames: rp.subexpNames(),
+		anchored:    anchoredAtStart(ast),
+		tailWidth:   -1,
+		prog:        compileProgram(ast, rp.groupCount, rp.flags&FlagLongest != 0),
+		prefilter:   newPrefilter(ast),
+	}
+	if _, maxLen, bounded := nodeWidth(ast); bounded && anchoredAtEnd(ast) {
+		re.tailWidth = maxLen
+	}
+	// The DFA only answers whether a line matches, which doesn't depend on
+	// leftmost-longest mode.
+	re.dfa = newLazyDFA(re.prog)
+	return re, nil
+}
+
+// MustCompile is like Compile but panics if the pattern doesn't parse.
+func MustCompile(expr string, flags Flags) *Regexp {
+	re, err := Compile(expr, flags)
+	if err != nil {
+		panic("regex: Compile(" + expr + "): " + err.Error())
+	}
+	return re
+}
+
+// String returns the source text of the pattern.
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// NumSubexp returns the number of capture groups in the pattern.
+func (re *Regexp) NumSubexp() int {
+	return re.numSubexp
+}
+
+// SubexpNames returns the name of every capture group, indexed by group
+// number. Index 0 stands for the whole match and unnamed groups have an
+// empty name. The slice must not be modified.
+func (re *Regexp) SubexpNames() []string {
+	return re.subexpNames
+}
+
+// SetMaxSteps limits each search to n backtracking steps, so a pattern like
+// '(a+)+$' can't run for ages on a crafted line. A search that runs out of
+// steps reports no match, or ErrStepLimit from the Try methods. 0 removes the
+// limit, except for leftmost-longest searches that backtrack, which keep
+// maxLongestSteps. It must not be called while the Regexp is in use.
+func (re *Regexp) SetMaxSteps(n int) {
+	re.maxSteps = n
+}
+
+// maxLongestSteps bounds a leftmost-longest search on the backtracker when
+// SetMaxSteps hasn't. It has to see every way the pattern can match, which
+// grows exponentially with nested repeats.
+const maxLongestSteps = 1_000_000
+
+// newBudget returns the step budget for one search.
+func (re *Regexp) newBudget() *stepBudget {
+	if re.maxSteps == 0 && re.prog == nil && re.flags&FlagLongest != 0 {
+		return newStepBudget(maxLongestSteps)
+	}
+	return newStepBudget(re.maxSteps)
+}
+
+// MatchString reports whether s contains a match of the pattern.
+func (re *Regexp) MatchString(s string) bool {
+	matched, _ := re.TryMatchString(s)
+	return matched
+}
+
+// Match reports whether b contains a match of the pattern.
+func (re *Regexp) Match(b []byte) bool {
+	return re.MatchString(string(b))
+}
+
+// TryMatchString is like MatchString but returns ErrStepLimit if the search
+// ran out of steps before it could decide.
+func (re *Regexp) TryMatchString(s string) (bool, error) {
+	budget := re.newBudget()
+	matched := re.matches(s, budget)
+	if err := budget.err(); err != nil {
+		return false, err
+	}
+	return matched, nil
+}
+
+// FindIndex returns the start and end of the first match in b, or nil if
+// there is none.
+func (re *Regexp) FindIndex(b []byte) []int {
+	return re.FindStringIndex(string(b))
+}
+
+// FindStringIndex returns the start and end of the first match in s, or nil
+// if there is none.
+func (re *Regexp) FindStringIndex(s string) []int {
+	if caps := re.FindStringSubmatchIndex(s); caps != nil {
+		return caps[:2]
+	}
+	return nil
+}
+
+// FindStringSubmatchIndex returns the first match in s as pairs of offsets:
+// the whole match and then each group, with -1 for a group that took no part.
+// It returns nil if there is no match.
+func (re *Regexp) FindStringSubmatchIndex(s string) []int {
+	return re.find(s, 0, re.newBudget())
+}
+
+// FindAllStringIndex returns the start and end of up to n successive
+// non-overlapping matches in s, or all of them if n is negative.
+func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	var all [][]int
+	for _, caps := range re.FindAllStringSubmatchIndex(s, n) {
+		all = append(all, caps[:2])
+	}
+	return all
+}
+
+// FindAllStringSubmatchIndex is like FindStringSubmatchIndex but returns up
+// to n successive non-overlapping matches, or all of them if n is negative.
+func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	var all [][]int
+	for m, err := range re.AllMatches(s) {
+		if err != nil || len(all) == n {
+			break
+		}
+		all = append(all, m.Captures)
+	}
+	return all
+}
+
+// AllMatches returns an iterator over the successive non-overlapping matches
+// in s, from left to right. An empty match right where the previous match
+// ended is skipped, so 'a*' finds "aa" and then "" at the end in "aab", not
+// an extra "" after the a's. If the search runs out of steps, the last
+// thing yielded is ErrStepLimit.
+func (re *Regexp) AllMatches(s string) iter.Seq2[MatchResult, error] {
+	return func(yield func(MatchResult, error) bool) {
+		budget := re.newBudget()
+		prevEnd := -1
+		for from := 0; from <= len(s); {
+			caps := re.find(s, from, budget)
+			if err := budget.err(); err != nil {
+				yield(MatchResult{}, err)
+				return
+			}
+			if caps == nil {
+				return
+			}
+			start, end := caps[0], caps[1]
+			if end > start || start != prevEnd {
+				if !yield(MatchResult{StartIdx: start, EndIdx: end, Captures: caps}, nil) {
+					return
+				}
+				prevEnd = end
+			}
+			if end > start {
+				from = end
+				continue
+			}
+			// Move past an empty match, or we'd find it again.
+			if end == len(s) {
+				return
+			}
+			_, size := utf8.DecodeRuneInString(s[end:])
+			from = end + size
+		}
+	}
+}
+
+// matches reports whether the pattern matches anywhere in s. It uses the
+// lazy DFA when it can, since no captures or positions are needed.
+func (re *Regexp) matches(s string, budget *stepBudget) bool {
+	if !re.prefilter.mayMatch(s) {
+		return false
+	}
+	if re.dfa != nil && re.tailWidth < 0 {
+		if matched, ok := re.dfa.matches(s); ok {
+			return matched
+		}
+	}
+	return re.find(s, 0, budget) != nil
+}
+
+// find returns the capture slots of the first match that starts at or after
+// from, or nil if there is none. Patterns the Pike VM can run are matched in
+// linear time; the rest, such as those with backreferences or lookarounds,
+// use the backtracker, which stops early and reports no match once budget
+// is used up.
+func (re *Regexp) find(s string, from int, budget *stepBudget) []int {
+	if re.tailWidth >= 0 {
+		// A match has to fit in the last tailWidth runes, so don't look earlier.
+		from = max(from, tailStart(s, re.tailWidth))
+	}
+	pf := re.prefilter
+	if !pf.mayMatch(s[from:]) {
+		return nil
+	}
+	if pf != nil && pf.prefix != "" && !re.anchored {
+		// No match can start before the first occurrence of the prefix.
+		from += strings.Index(s[from:], pf.prefix)
+	}
+	if re.prog != nil {
+		_, _, caps := re.prog.match(s, from, re.anchored)
+		return caps
+	}
+
+	var startPositions []int
+	if re.anchored {
+		if from == 0 {
+			startPositions = []int{0}
+		}
+	} else if pf != nil && pf.prefix != "" {
+		// A match can only start where the prefix occurs.
+		for i := from; ; {
+			idx := strings.Index(s[i:], pf.prefix)
+			if idx < 0 {
+				break
+			}
+			startPositions = append(startPositions, i+idx)
+			_, size := utf8.DecodeRuneInString(s[i+idx:])
+			i += idx + size
+		}
+	} else {
+		// Only start on code point boundaries, so a match never begins mid-rune.
+		for i := from; i <= len(s); {
+			startPositions = append(startPositions, i)
+			if i == len(s) {
+				break
+			}
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+		}
+	}
+
+	for _, pos := range startPositions {
+		possibilities := matchPossibilities(re.ast, s, pos, newCaptures(re.numSubexp), budget)
+		var best MatchResult
+		found := false
+		if re.flags&FlagLongest != 0 {
+			// Every possibility has to be seen to know which is longest.
+			best, found = longestMatch(possibilities)
+		} else {
+			best, found = firstMatch(possibilities)
+		}
+		if budget.err() != nil {
+			break
+		}
+		if found {
+			caps := best.Captures
+			caps[0], caps[1] = best.StartIdx, best.EndIdx
+			return caps
+		}
+	}
+	return nil
+}
+
+// tailStart returns the offset n runes before the end of s, or 0.
+func tailStart(s string, n int) int {
+	pos := len(s)
+	for ; n > 0 && pos > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:pos])
+		pos -= size
+	}
+	return pos
+}
+
+// anchoredAtStart reports whether every match of node has to begin at the
+// start of the text: each alternative has to start with '^' outside
+// multiline mode or with '\A', possibly after other zero-width assertions.
+func anchoredAtStart(node regexNode) bool {
+	switch n := node.(type) {
+	case *anchorNode:
+		return n.Type == 'A' || (n.Type == 's' && !n.Multiline)
+	case *concatenationNode:
+		for _, child := range n.NodeChildren {
+			if anchoredAtStart(child) {
+				return true
+			}
+			if !isZeroWidth(child) {
+				return false
+			}
+		}
+		return false
+	case *alternationNode:
+		for _, branch := range n.Branches {
+			if !anchoredAtStart(branch) {
+				return false
+			}
+		}
+		return true
+	case *captureGroupNode:
+		return anchoredAtStart(n.Child)
+	case *groupNode:
+		return anchoredAtStart(n.Child)
+	case *atomicGroupNode:
+		return anchoredAtStart(n.Child)
+	case *quantifierNode:
+		return n.Min > 0 && anchoredAtStart(n.NodeChildren)
+	}
+	return false
+}
+
+// anchoredAtEnd is the mirror of anchoredAtStart: every match of node has to
+// finish at the end of the text, with '$' outside multiline mode or '\z'.
+// '\Z' doesn't count, since it also holds before a final newline.
+func anchoredAtEnd(node regexNode) bool {
+	switch n := node.(type) {
+	case *anchorNode:
+		return n.Type == 'z' || (n.Type == 'e' && !n.Multiline)
+	case *concatenationNode:
+		for i := len(n.NodeChildren) - 1; i >= 0; i-- {
+			child := n.NodeChildren[i]
+			if anchoredAtEnd(child) {
+				return true
+			}
+			if !isZeroWidth(child) {
+				return false
+			}
+		}
+		return false
+	case *alternationNode:
+		for _, branch := range n.Branches {
+			if !anchoredAtEnd(branch) {
+				return false
+			}
+		}
+		return true
+	case *captureGroupNode:
+		return anchoredAtEnd(n.Child)
+	case *groupNode:
+		return anchoredAtEnd(n.Child)
+	case *atomicGroupNode:
+		return anchoredAtEnd(n.Child)
+	case *quantifierNode:
+		return n.Min > 0 && anchoredAtEnd(n.NodeChildren)
+	}
+	return false
+}
+
+// isZeroWidth reports whether node is an assertion that never consumes input.
+func isZeroWidth(node regexNode) bool {
+	switch node.(type) {
+	case *anchorNode, *lookaroundNode:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package regex
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		flags   Flags
+		input   string
+		want    bool
+	}{
+		{`\d+`, 0, "abc123", true},
+		{`\d+`, 0, "abc", false},
+		{`^abc$`, 0, "abc", true},
+		{`^abc$`, 0, "abcd", false},
+		{`^$`, 0, "", true},
+		{`(\w+) \1`, 0, "hello hello", true},
+		{`(\w+) \1`, 0, "hello world", false},
+		{`foo(?=bar)`, 0, "foobaz foobar", true},
+		{`HELLO`, FlagFoldCase, "say hello", true},
+		{`\bve\b`, FlagUnicodeClasses, "naïve", false},
+		{`^b$`, FlagMultiline, "a\nb\nc", true},
+		{`^\w+$`, 0, "x!", false},
+	}
+	for _, tt := range tests {
+		re := MustCompile(tt.pattern, tt.flags)
+		if got := re.MatchString(tt.input); got != tt.want {
+			t.Errorf("%q.MatchString(%q) = %v, want %v", tt.pattern, tt.input, got, tt.want)
+		}
+		if got := re.Match([]byte(tt.input)); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.pattern, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFindIndex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    []int
+	}{
+		{`b+`, "abbbc", []int{1, 4}},
+		{`b+?`, "abbbc", []int{1, 2}},
+		{`x`, "abc", nil},
+		{`a*`, "bbb", []int{0, 0}},
+		{`c$`, "abc", []int{2, 3}},
+		{`é+`, "caféé!", []int{3, 7}},
+		{`cat|category`, "category", []int{0, 3}},
+	}
+	for _, tt := range tests {
+		re := MustCompile(tt.pattern, 0)
+		if got := re.FindIndex([]byte(tt.input)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q.FindIndex(%q) = %v, want %v", tt.pattern, tt.input, got, tt.want)
+		}
+		if got := re.FindStringIndex(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q.FindStringIndex(%q) = %v, want %v", tt.pattern, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFindStringSubmatchIndex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		flags   Flags
+		input   string
+		want    []int
+	}{
+		{`(\d+)-(\d+)`, 0, "tel 555-1234", []int{4, 12, 4, 7, 8, 12}},
+		{`(a)|(b)`, 0, "b", []int{0, 1, -1, -1, 0, 1}},
+		{`(a+)+`, 0, "aaa", []int{0, 3, 0, 3}},
+		{`(x)?y`, 0, "y", []int{0, 1, -1, -1}},
+		{`(?:ab)+(c)`, 0, "ababc", []int{0, 5, 4, 5}},
+		{`(a|ab)(c|bcd)`, FlagLongest, "abcd", []int{0, 4, 0, 1, 1, 4}},
+		{`(a|ab)(b*)`, FlagLongest, "abb", []int{0, 3, 0, 2, 2, 3}},
+		{`x*(a|ab)(\w)?`, FlagLongest, "ab", []int{0, 2, 0, 2, -1, -1}},
+		{`(q)`, 0, "abc", nil},
+	}
+	for _, tt := range tests {
+		re := MustCompile(tt.pattern, tt.flags)
+		if got := re.FindStringSubmatchIndex(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q.FindStringSubmatchIndex(%q) = %v, want %v", tt.pattern, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFindAllStringSubmatchIndex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		n       int
+		want    [][]int
+	}{
+		{`(\w)(\d)`, "a1 b2 c3", -1, [][]int{{0, 2, 0, 1, 1, 2}, {3, 5, 3, 4, 4, 5}, {6, 8, 6, 7, 7, 8}}},
+		{`(\w)(\d)`, "a1 b2 c3", 2, [][]int{{0, 2, 0, 1, 1, 2}, {3, 5, 3, 4, 4, 5}}},
+		{`(\w)(\d)`, "a1 b2 c3", 5, [][]int{{0, 2, 0, 1, 1, 2}, {3, 5, 3, 4, 4, 5}, {6, 8, 6, 7, 7, 8}}},
+		{`(\w)(\d)`, "a1 b2 c3", 0, nil},
+		{`(\w)(\d)`, "none", -1, nil},
+		{`a*`, "aab", -1, [][]int{{0, 2}, {3, 3}}},
+		{`x*`, "ab", -1, [][]int{{0, 0}, {1, 1}, {2, 2}}},
+	}
+	for _, tt := range tests {
+		re := MustCompile(tt.pattern, 0)
+		if got := re.FindAllStringSubmatchIndex(tt.input, tt.n); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q.FindAllStringSubmatchIndex(%q, %d) = %v, want %v", tt.pattern, tt.input, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSubexps(t *testing.T) {
+	tests := []struct {
+		pattern string
+		num     int
+		names   []string
+	}{
+		{`abc`, 0, []string{""}},
+		{`(a)(?:b)(c)`, 2, []string{"", "", ""}},
+		{`(?P<year>\d+)-(\d+)-(?<day>\d+)`, 3, []string{"", "year", "", "day"}},
+		{`(?<outer>a(?<inner>b))`, 2, []string{"", "outer", "inner"}},
+	}
+	for _, tt := range tests {
+		re := MustCompile(tt.pattern, 0)
+		if got := re.NumSubexp(); got != tt.num {
+			t.Errorf("%q.NumSubexp() = %d, want %d", tt.pattern, got, tt.num)
+		}
+		if got := re.SubexpNames(); !reflect.DeepEqual(got, tt.names) {
+			t.Errorf("%q.SubexpNames() = %q, want %q", tt.pattern, got, tt.names)
+		}
+	}
+}
+
+func TestMustCompile(t *testing.T) {
+	re := MustCompile(`a+b`, 0)
+	if re.String() != `a+b` {
+		t.Errorf("String() = %q, want %q", re.String(), `a+b`)
+	}
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("MustCompile(`a(b`) did not panic")
+		}
+		if msg := fmt.Sprint(r); !strings.HasPrefix(msg, "regex: Compile(") {
+			t.Errorf("panic message = %q, want a regex: Compile prefix", msg)
+		}
+	}()
+	MustCompile(`a(b`, 0)
+}
+
+func TestCompileError(t *testing.T) {
+	_, err := Compile(`(?<x>a)\k<y>`, 0)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Compile error = %v, want a *ParseError", err)
+	}
+	if parseErr.Pattern != `(?<x>a)\k<y>` {
+		t.Errorf("ParseError.Pattern = %q", parseErr.Pattern)
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	re := MustCompile(`(a|aa)+\1c|y`, 0)
+	re.SetMaxSteps(1000)
+	if _, err := re.TryMatchString(strings.Repeat("a", 30) + "b"); !errors.Is(err, ErrStepLimit) {
+		t.Errorf("TryMatchString error = %v, want ErrStepLimit", err)
+	}
+}
+
+// TestConcurrentUse shares each Regexp between goroutines, so that running
+// with -race checks the lazy DFA cache and the other shared state. The first
+// pattern needs more DFA states than the cache holds, so scans flush it while
+// others are reading it.
+func TestConcurrentUse(t *testing.T) {
+	patterns := []struct {
+		expr  string
+		input func(i int) string
+		want  func(s string) bool
+	}{
+		{
+			`(a|b)*a(a|b){12}x`,
+			func(i int) string {
+				rng := rand.New(rand.NewSource(int64(i)))
+				var sb strings.Builder
+				for j := 0; j < 200; j++ {
+					sb.WriteByte("ab"[rng.Intn(2)])
+				}
+				return sb.String() + "x"
+			},
+			func(s string) bool { return s[len(s)-14] == 'a' },
+		},
+		{
+			`(\w+)@(\w+)\.com`,
+			func(i int) string { return fmt.Sprintf("user%d@host%d.com", i, i%3) + strings.Repeat(" ", i%2) },
+			func(string) bool { return true },
+		},
+		{
+			`(\w)\1`,
+			func(i int) string { return fmt.Sprintf("x%dy%d", i, i*11) },
+			func(s string) bool {
+				for j := 1; j < len(s); j++ {
+					if s[j] == s[j-1] {
+						return true
+					}
+				}
+				return false
+			},
+		},
+	}
+
+	for _, p := range patterns {
+		re := MustCompile(p.expr, 0)
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := g; i < 400; i += 8 {
+					s := p.input(i)
+					want := p.want(s)
+					if got := re.MatchString(s); got != want {
+						t.Errorf("%q.MatchString(%q) = %v, want %v", p.expr, s, got, want)
+					}
+					if got := re.FindStringSubmatchIndex(s) != nil; got != want {
+						t.Errorf("%q.FindStringSubmatchIndex(%q) found = %v, want %v", p.expr, s, got, want)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+	}
+}
@@ -170,5 +170,813 @@ fi
 echo "Test 12 passed."
 echo ""
 
+# --- Run test 13: Bounded repetition ---
+echo -e "\033[1m -- Bounded repetition -- \033[0m"
+set +e  # Allow commands to fail without exiting
+echo -n "555-1234" | ./ast -E "\d{3}-\d{4}"
+code1=$?
+echo -n "aaa" | ./ast -E "^a{2}$"
+code2=$?
+echo -n "aaaa" | ./ast -E "^a{2,}$"
+code3=$?
+echo -n "ababc" | ./ast -E "^(ab){1,2}c$"
+code4=$?
+echo -n "aa" | ./ast -E "a{3,2}"
+code5=$?
+set -e
+
+if [ $code1 -ne 0 ]; then
+  echo "Expected exit code 0 for '555-1234', got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 1 ]; then
+  echo "Expected exit code 1 for 'aaa', got $code2"
+  exit 1
+fi
+
+if [ $code3 -ne 0 ]; then
+  echo "Expected exit code 0 for 'aaaa', got $code3"
+  exit 1
+fi
+
+if [ $code4 -ne 0 ]; then
+  echo "Expected exit code 0 for 'ababc', got $code4"
+  exit 1
+fi
+
+if [ $code5 -ne 2 ]; then
+  echo "Expected exit code 2 for inverted range, got $code5"
+  exit 1
+fi
+echo "Test 13 passed."
+echo ""
+
+# --- Run test 14: Lazy quantifiers ---
+echo -e "\033[1m -- Lazy quantifiers -- \033[0m"
+out1=$(echo -n "<a><bb>" | ./ast -o -E "<.+?>")
+out2=$(echo -n "<a><bb>" | ./ast -o -E "<.+>")
+out3=$(echo -n "aaaa" | ./ast -o -E "a{2,3}?")
+
+if [ "$out1" != "$(printf '<a>\n<bb>')" ]; then
+  echo "Expected '<a>' and '<bb>' for lazy '<.+?>', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "<a><bb>" ]; then
+  echo "Expected '<a><bb>' for greedy '<.+>', got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != "$(printf 'aa\naa')" ]; then
+  echo "Expected 'aa' twice for 'a{2,3}?', got '$out3'"
+  exit 1
+fi
+echo "Test 14 passed."
+echo ""
+
+# --- Run test 15: Ranges and escapes in character sets ---
+echo -e "\033[1m -- Ranges and escapes in character sets -- \033[0m"
+out1=$(echo -n "ABC def" | ./ast -o -E "[a-z]+")
+out2=$(echo -n "x]a]y" | ./ast -o -E "[]a]+")
+out3=$(echo -n "ab1_2c" | ./ast -o -E "[\d_]+")
+out4=$(echo -n "a]-b" | ./ast -o -E "[\]-]+")
+
+if [ "$out1" != "def" ]; then
+  echo "Expected 'def' for '[a-z]+', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "]a]" ]; then
+  echo "Expected ']a]' for '[]a]+', got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != "1_2" ]; then
+  echo "Expected '1_2' for '[\d_]+', got '$out3'"
+  exit 1
+fi
+
+if [ "$out4" != "]-" ]; then
+  echo "Expected ']-' for '[\]-]+', got '$out4'"
+  exit 1
+fi
+echo "Test 15 passed."
+echo ""
+
+# --- Run test 16: POSIX bracket classes ---
+echo -e "\033[1m -- POSIX bracket classes -- \033[0m"
+out1=$(echo -n "ab12c" | ./ast -o -E "[[:digit:]]+")
+out2=$(echo -n "abC1d" | ./ast -o -E "[[:upper:][:digit:]]+")
+out3=$(echo -n "ab_-1c" | ./ast -o -E "[^[:alpha:]]+")
+set +e
+echo -n "a" | ./ast -E "[[:foo:]]"
+code1=$?
+set -e
+
+if [ "$out1" != "12" ]; then
+  echo "Expected '12' for '[[:digit:]]+', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "C1" ]; then
+  echo "Expected 'C1' for '[[:upper:][:digit:]]+', got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != "_-1" ]; then
+  echo "Expected '_-1' for '[^[:alpha:]]+', got '$out3'"
+  exit 1
+fi
+
+if [ $code1 -ne 2 ]; then
+  echo "Expected exit code 2 for unknown class, got $code1"
+  exit 1
+fi
+echo "Test 16 passed."
+echo ""
+
+# --- Run test 17: Shorthand classes ---
+echo -e "\033[1m -- Shorthand classes -- \033[0m"
+out1=$(echo -n "ab12c" | ./ast -o -E "\D+")
+out2=$(echo -n "ab, c" | ./ast -o -E "\W+")
+out3=$(echo -n "a  b" | ./ast -o -E "a\s+b")
+out4=$(echo -n "héllo wörld" | ./ast --unicode -o -E "\w+")
+
+if [ "$out1" != "$(printf 'ab\nc')" ]; then
+  echo "Expected 'ab' and 'c' for '\D+', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != ", " ]; then
+  echo "Expected ', ' for '\W+', got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != "a  b" ]; then
+  echo "Expected 'a  b' for 'a\s+b', got '$out3'"
+  exit 1
+fi
+
+if [ "$out4" != "$(printf 'héllo\nwörld')" ]; then
+  echo "Expected 'héllo' and 'wörld' for Unicode '\w+', got '$out4'"
+  exit 1
+fi
+echo "Test 17 passed."
+echo ""
+
+# --- Run test 18: Word boundaries ---
+echo -e "\033[1m -- Word boundaries -- \033[0m"
+out1=$(echo -n "foo foobar afoo foo" | ./ast -o -E "\bfoo\b")
+out2=$(echo -n "foo fooo" | ./ast -o -E "\Boo\B")
+set +e
+echo -n "foobar" | ./ast -E "\bfoo\b"
+code1=$?
+echo -n "naïve" | ./ast --unicode -o -E "\bve\b"
+code2=$?
+set -e
+out3=$(echo -n "naïve café" | ./ast --unicode -o -E "\bcafé\b")
+
+if [ "$out1" != "$(printf 'foo\nfoo')" ]; then
+  echo "Expected 'foo' twice for '\bfoo\b', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "oo" ]; then
+  echo "Expected 'oo' for '\Boo\B', got '$out2'"
+  exit 1
+fi
+
+if [ $code1 -ne 1 ]; then
+  echo "Expected exit code 1 for 'foobar', got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 1 ]; then
+  echo "Expected exit code 1 for '\bve\b' on 'naïve' with --unicode, got $code2"
+  exit 1
+fi
+
+if [ "$out3" != "café" ]; then
+  echo "Expected 'café' for '\bcafé\b' with --unicode, got '$out3'"
+  exit 1
+fi
+echo "Test 18 passed."
+echo ""
+
+# --- Run test 19: Non-capturing groups ---
+echo -e "\033[1m -- Non-capturing groups -- \033[0m"
+set +e
+echo -n "ababcc" | ./ast -E "(?:ab)+(c)\1"
+code1=$?
+echo -n "bcb" | ./ast -E "(?:a|b)(c)\1"
+code2=$?
+set -e
+
+if [ $code1 -ne 0 ]; then
+  echo "Expected exit code 0 for 'ababcc', got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 1 ]; then
+  echo "Expected exit code 1 for 'bcb', got $code2"
+  exit 1
+fi
+echo "Test 19 passed."
+echo ""
+
+# --- Run test 20: Named groups ---
+echo -e "\033[1m -- Named groups -- \033[0m"
+out1=$(echo -n "2020-2020 2020-2021" | ./ast -o -E "(?P<year>\d+)-\k<year>")
+set +e
+echo -n "hey hey" | ./ast -E "(?<w>\w+) \k<w>"
+code1=$?
+echo -n "ab" | ./ast -E "(?<w>a)(?<w>b)" 2>/dev/null
+code2=$?
+echo -n "a" | ./ast -E "(a)\k<nope>" 2>/dev/null
+code3=$?
+set -e
+
+if [ "$out1" != "$(printf '2020-2020\n20-20')" ]; then
+  echo "Expected '2020-2020' and '20-20' for named backreference, got '$out1'"
+  exit 1
+fi
+
+if [ $code1 -ne 0 ]; then
+  echo "Expected exit code 0 for 'hey hey', got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 2 ]; then
+  echo "Expected exit code 2 for duplicate name, got $code2"
+  exit 1
+fi
+
+if [ $code3 -ne 2 ]; then
+  echo "Expected exit code 2 for unknown name, got $code3"
+  exit 1
+fi
+echo "Test 20 passed."
+echo ""
+
+# --- Run test 21: Lookaround assertions ---
+echo -e "\033[1m -- Lookaround assertions -- \033[0m"
+out1=$(echo -n "hi there!" | ./ast -o -E "\w+(?=!)")
+out2=$(echo -n "cost \$42 or 17" | ./ast -o -E "(?<=\\$)\d+")
+out3=$(echo -n "cost \$42 or 17" | ./ast -o -E "(?<!\\$)\b\d+")
+set +e
+echo -n "password=[REDACTED]" | ./ast -E "password=(?!\[REDACTED\])"
+code1=$?
+echo -n "ax" | ./ast -E "(?<=a+)x" 2>/dev/null
+code2=$?
+set -e
+
+if [ "$out1" != "there" ]; then
+  echo "Expected 'there' for lookahead, got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "42" ]; then
+  echo "Expected '42' for lookbehind, got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != "17" ]; then
+  echo "Expected '17' for negative lookbehind, got '$out3'"
+  exit 1
+fi
+
+if [ $code1 -ne 1 ]; then
+  echo "Expected exit code 1 for redacted password, got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 2 ]; then
+  echo "Expected exit code 2 for unbounded lookbehind, got $code2"
+  exit 1
+fi
+echo "Test 21 passed."
+echo ""
+
+# --- Run test 22: UTF-8 input ---
+echo -e "\033[1m -- UTF-8 input -- \033[0m"
+out1=$(echo -n "café!" | ./ast -o -E "caf.")
+out2=$(echo -n "x日本語" | ./ast -o -E "[日本]+")
+out3=$(echo -n "ééa" | ./ast -o -E "é+")
+set +e
+echo -n "héé" | ./ast -E "^.{3}$"
+code1=$?
+set -e
+
+if [ "$out1" != "café" ]; then
+  echo "Expected 'café' for 'caf.', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "日本" ]; then
+  echo "Expected '日本' for '[日本]+', got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != "éé" ]; then
+  echo "Expected 'éé' for 'é+', got '$out3'"
+  exit 1
+fi
+
+if [ $code1 -ne 0 ]; then
+  echo "Expected exit code 0 for 'héé', got $code1"
+  exit 1
+fi
+echo "Test 22 passed."
+echo ""
+
+# --- Run test 23: Unicode property classes ---
+echo -e "\033[1m -- Unicode property classes -- \033[0m"
+out1=$(echo -n "Ärger 123 ελληνικά" | ./ast -o -E "\p{L}+")
+out2=$(echo -n "abc αβγ" | ./ast -o -E "\p{Greek}+")
+out3=$(echo -n "x α1β y" | ./ast -o -E "[\p{Greek}\d]+")
+out4=$(echo -n "ab12c" | ./ast -o -E "\P{N}+")
+
+if [ "$out1" != "$(printf 'Ärger\nελληνικά')" ]; then
+  echo "Expected 'Ärger' and 'ελληνικά' for '\p{L}+', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "αβγ" ]; then
+  echo "Expected 'αβγ' for '\p{Greek}+', got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != "α1β" ]; then
+  echo "Expected 'α1β' for '[\p{Greek}\d]+', got '$out3'"
+  exit 1
+fi
+
+if [ "$out4" != "$(printf 'ab\nc')" ]; then
+  echo "Expected 'ab' and 'c' for '\P{N}+', got '$out4'"
+  exit 1
+fi
+echo "Test 23 passed."
+echo ""
+
+# --- Run test 24: Case-insensitive matching ---
+echo -e "\033[1m -- Case-insensitive matching -- \033[0m"
+out1=$(echo -n "xABCd" | ./ast -i -o -E "[a-c]+")
+out2=$(echo -n "ΣσςX" | ./ast -i -o -E "σ+")
+set +e
+echo -n "abAB" | ./ast -i -E "^(ab)\1$"
+code1=$?
+echo -n "HELLO" | ./ast --smart-case -E "hello"
+code2=$?
+echo -n "HELLO" | ./ast --smart-case -E "Hello"
+code3=$?
+set -e
+
+if [ "$out1" != "ABC" ]; then
+  echo "Expected 'ABC' for -i '[a-c]+', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "Σσς" ]; then
+  echo "Expected 'Σσς' for -i 'σ+', got '$out2'"
+  exit 1
+fi
+
+if [ $code1 -ne 0 ]; then
+  echo "Expected exit code 0 for -i backreference, got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 0 ]; then
+  echo "Expected exit code 0 for smart case 'hello', got $code2"
+  exit 1
+fi
+
+if [ $code3 -ne 1 ]; then
+  echo "Expected exit code 1 for smart case 'Hello', got $code3"
+  exit 1
+fi
+echo "Test 24 passed."
+echo ""
+
+# --- Run test 25: Inline flags ---
+echo -e "\033[1m -- Inline flags -- \033[0m"
+out1=$(echo -n "aBc aBC" | ./ast -o -E "a(?i:b)c")
+out2=$(echo -n "AB Ab" | ./ast -i -o -E "a(?-i)b")
+out3=$(echo -n "a1b" | ./ast -o -E "(?x) a \d  b  # comment")
+set +e
+echo -n "HeLLo" | ./ast -E "(?i)hello"
+code1=$?
+set -e
+
+if [ "$out1" != "aBc" ]; then
+  echo "Expected 'aBc' for 'a(?i:b)c', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "Ab" ]; then
+  echo "Expected 'Ab' for 'a(?-i)b', got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != "a1b" ]; then
+  echo "Expected 'a1b' for extended mode, got '$out3'"
+  exit 1
+fi
+
+if [ $code1 -ne 0 ]; then
+  echo "Expected exit code 0 for '(?i)hello', got $code1"
+  exit 1
+fi
+echo "Test 25 passed."
+echo ""
+
+# --- Run test 26: Multi-digit and relative backreferences ---
+echo -e "\033[1m -- Multi-digit and relative backreferences -- \033[0m"
+set +e
+echo -n "abcdefghijj" | ./ast -E "(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)\10"
+code1=$?
+echo -n "abbab" | ./ast -E "(a)(b)\g{-1}\g{1}\g2"
+code2=$?
+echo -n "aa" | ./ast -E "(a)\2" 2>/dev/null
+code3=$?
+set -e
+
+if [ $code1 -ne 0 ]; then
+  echo "Expected exit code 0 for '\10', got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 0 ]; then
+  echo "Expected exit code 0 for '\g' references, got $code2"
+  exit 1
+fi
+
+if [ $code3 -ne 2 ]; then
+  echo "Expected exit code 2 for undefined group, got $code3"
+  exit 1
+fi
+echo "Test 26 passed."
+echo ""
+
+# --- Run test 27: Atomic groups and possessive quantifiers ---
+echo -e "\033[1m -- Atomic groups and possessive quantifiers -- \033[0m"
+out1=$(echo -n 'say "hi"' | ./ast -o -E '"[^"]*+"')
+set +e
+echo -n "aaaa" | ./ast -E "a++a"
+code1=$?
+echo -n "aaaa" | ./ast -E "(?>a+)a"
+code2=$?
+echo -n "abc" | ./ast -E "(?>a|ab)c"
+code3=$?
+set -e
+
+if [ "$out1" != '"hi"' ]; then
+  echo "Expected '\"hi\"' for possessive quote, got '$out1'"
+  exit 1
+fi
+
+if [ $code1 -ne 1 ]; then
+  echo "Expected exit code 1 for 'a++a', got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 1 ]; then
+  echo "Expected exit code 1 for '(?>a+)a', got $code2"
+  exit 1
+fi
+
+if [ $code3 -ne 1 ]; then
+  echo "Expected exit code 1 for '(?>a|ab)c', got $code3"
+  exit 1
+fi
+echo "Test 27 passed."
+echo ""
+
+# --- Run test 28: Absolute anchors and multiline mode ---
+echo -e "\033[1m -- Absolute anchors and multiline mode -- \033[0m"
+out1=$(printf 'ab\nb\0' | ./ast -z -o --multiline -E "^b" | tr '\0' '\n')
+out2=$(printf 'a\nb\n\0' | ./ast -z -o -E "b\Z" | tr '\0' '\n')
+set +e
+printf 'a\nb\nc\0' | ./ast -z -E "^b$" > /dev/null
+code1=$?
+printf 'a\nb\nc\0' | ./ast -z -E "(?m)^b$" > /dev/null
+code2=$?
+echo -n "hello" | ./ast -E "\Ahello\z"
+code3=$?
+set -e
+
+if [ "$out1" != "b" ]; then
+  echo "Expected 'b' for multiline '^b', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "b" ]; then
+  echo "Expected 'b' for 'b\Z', got '$out2'"
+  exit 1
+fi
+
+if [ $code1 -ne 1 ]; then
+  echo "Expected exit code 1 for '^b$' on a record, got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 0 ]; then
+  echo "Expected exit code 0 for '(?m)^b$' on a record, got $code2"
+  exit 1
+fi
+
+if [ $code3 -ne 0 ]; then
+  echo "Expected exit code 0 for '\Ahello\z', got $code3"
+  exit 1
+fi
+echo "Test 28 passed."
+echo ""
+
+# --- Run test 29: Linear-time matching ---
+echo -e "\033[1m -- Linear-time matching -- \033[0m"
+long=$(printf 'a%.0s' $(seq 1 2000))
+set +e
+echo -n "$long" | timeout 5 ./ast -E "(a*)*b"
+code1=$?
+echo -n "${long}b" | timeout 5 ./ast -E "(a|aa)*b" > /dev/null
+code2=$?
+set -e
+
+if [ $code1 -ne 1 ]; then
+  echo "Expected exit code 1 for '(a*)*b', got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 0 ]; then
+  echo "Expected exit code 0 for '(a|aa)*b', got $code2"
+  exit 1
+fi
+echo "Test 29 passed."
+echo ""
+
+# --- Run test 30: Line filtering with the lazy DFA ---
+echo -e "\033[1m -- Line filtering with the lazy DFA -- \033[0m"
+out1=$(printf 'id=12\nid=\nx id=7 y\n' | ./ast -E "id=\d+$")
+# The random line needs more DFA states than the cache holds, so it falls back;
+# the lines after it are scanned with a flushed cache.
+random=$(awk 'BEGIN { srand(1); for (i = 0; i < 20000; i++) printf (rand() < 0.5 ? "a" : "b"); print "bbbbbbbbbbbbbc" }')
+out2=$(printf 'aaaaaaaaaaaaaaaaaaaac\n%s\nbbbbbbbbbbbbbbbbbbbbc\nbabbbbbbbbbbbbc\n' "$random" | ./ast -E "(a|b)*a(a|b){12}c")
+
+if [ "$out1" != "id=12" ]; then
+  echo "Expected 'id=12' for 'id=\d+$', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "$(printf 'aaaaaaaaaaaaaaaaaaaac\nbabbbbbbbbbbbbc')" ]; then
+  echo "Expected only the lines with an 'a' 13 before the 'c' for the fallback pattern, got '$out2'"
+  exit 1
+fi
+echo "Test 30 passed."
+echo ""
+
+# --- Run test 31: Backtracking step limit ---
+echo -e "\033[1m -- Backtracking step limit -- \033[0m"
+long=$(printf 'a%.0s' $(seq 1 30))
+set +e
+out1=$(printf '%sb\nxay\n' "$long" | timeout 5 ./ast --max-steps 10000 -E "(a|aa)+\1c|y" 2> /tmp/mygrep_err)
+code1=$?
+printf '%sb\nxay\n' "$long" | timeout 5 ./ast --max-steps 10000 --abort-on-limit -E "(a|aa)+\1c|y" > /dev/null 2>&1
+code2=$?
+# The only line that could match was skipped, so there's no clean "no match".
+printf '%sb\nxaz\n' "$long" | timeout 5 ./ast --max-steps 10000 -E "(a|aa)+\1c|y" > /dev/null 2>&1
+code3=$?
+set -e
+
+if [ "$out1" != "xay" ] || [ $code1 -ne 0 ]; then
+  echo "Expected 'xay' and exit code 0 when skipping, got '$out1' and $code1"
+  exit 1
+fi
+
+if ! grep -q "(standard input):1:" /tmp/mygrep_err; then
+  echo "Expected the skipped line to be reported on stderr, got '$(cat /tmp/mygrep_err)'"
+  exit 1
+fi
+rm -f /tmp/mygrep_err
+
+if [ $code2 -ne 2 ]; then
+  echo "Expected exit code 2 with --abort-on-limit, got $code2"
+  exit 1
+fi
+
+if [ $code3 -ne 2 ]; then
+  echo "Expected exit code 2 when a line was skipped and nothing matched, got $code3"
+  exit 1
+fi
+echo "Test 31 passed."
+echo ""
+
+# --- Run test 32: Literal prefiltering ---
+echo -e "\033[1m -- Literal prefiltering -- \033[0m"
+out1=$(printf 'x req_1 y req_22 req\n' | ./ast -o -E "(?<=\s)req_\d+")
+long=$(printf 'ab%.0s' $(seq 1 20000))
+set +e
+echo -n "$long" | timeout 5 ./ast -E "(\w+)\1+FATAL"
+code1=$?
+set -e
+
+if [ "$out1" != $'req_1\nreq_22' ]; then
+  echo "Expected 'req_1' and 'req_22' for '(?<=\s)req_\d+', got '$out1'"
+  exit 1
+fi
+
+if [ $code1 -ne 1 ]; then
+  echo "Expected exit code 1 for a line without the required literal, got $code1"
+  exit 1
+fi
+echo "Test 32 passed."
+echo ""
+
+# --- Run test 33: POSIX leftmost-longest mode ---
+echo -e "\033[1m -- POSIX leftmost-longest mode -- \033[0m"
+out1=$(echo -n "ab" | ./ast -o -E "a|ab")
+out2=$(echo -n "ab" | ./ast -o --posix -E "a|ab")
+out3=$(echo -n "xaay abcd" | ./ast -o --posix -E "a+?|(a|ab)(c|bcd)")
+# Leftmost-longest runs on the Pike VM, so nested repeats stay linear.
+ones=$(printf '1%.0s' $(seq 1 29))
+out4=$(echo -n "${ones}x" | timeout 5 ./ast -o --posix -E "(\w|\d)+x")
+
+if [ "$out1" != "a" ]; then
+  echo "Expected 'a' for leftmost-first 'a|ab', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "ab" ]; then
+  echo "Expected 'ab' for leftmost-longest 'a|ab', got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != $'aa\nabcd' ]; then
+  echo "Expected 'aa' and 'abcd' for leftmost-longest, got '$out3'"
+  exit 1
+fi
+
+if [ "$out4" != "${ones}x" ]; then
+  echo "Expected the whole line for '(\w|\d)+x' in leftmost-longest mode, got '$out4'"
+  exit 1
+fi
+echo "Test 33 passed."
+echo ""
+
+# --- Run test 34: Parse error diagnostics ---
+echo -e "\033[1m -- Parse error diagnostics -- \033[0m"
+set +e
+err1=$(echo -n "abc" | ./ast -E "*abc" 2>&1)
+err2=$(echo -n "abc" | ./ast -E "a(bc" 2>&1)
+err3=$(echo -n "abc" | ./ast -E "ab)c" 2>&1)
+err4=$(echo -n "abc" | ./ast -E "a()" 2>&1)
+err5=$(echo -n "abc" | ./ast -E "ab[c" 2>&1)
+code5=$?
+err6=$(echo -n "xyz" | ./ast -E "x(|a)" 2>&1)
+code6=$?
+err7=$(echo -n "xyz" | ./ast -E "x(a|)" 2>&1)
+set -e
+
+if [ "$err1" != $'error: invalid pattern: nothing to repeat at position 0: \'*\' has to follow something it can repeat\n  *abc\n  ^' ]; then
+  echo "Expected a dangling quantifier error, got '$err1'"
+  exit 1
+fi
+
+if [ "$err2" != $'error: invalid pattern: missing closing \')\' at position 1\n  a(bc\n   ^' ]; then
+  echo "Expected a missing ')' error, got '$err2'"
+  exit 1
+fi
+
+if [ "$err3" != $'error: invalid pattern: unmatched \')\' at position 2\n  ab)c\n    ^' ]; then
+  echo "Expected an unmatched ')' error, got '$err3'"
+  exit 1
+fi
+
+if [ "$err4" != $'error: invalid pattern: empty group at position 1\n  a()\n   ^' ]; then
+  echo "Expected an empty group error, got '$err4'"
+  exit 1
+fi
+
+if [ "$err5" != $'error: invalid pattern: unterminated character set at position 2\n  ab[c\n    ^' ] || [ $code5 -ne 2 ]; then
+  echo "Expected an unterminated set error and exit code 2, got '$err5' and $code5"
+  exit 1
+fi
+
+if [ "$err6" != $'error: invalid pattern: empty alternative at position 2: nothing before \'|\'\n  x(|a)\n    ^' ] || [ $code6 -ne 2 ]; then
+  echo "Expected an empty leading alternative error and exit code 2, got '$err6' and $code6"
+  exit 1
+fi
+
+if [ "$err7" != $'error: invalid pattern: empty alternative at position 3: nothing after \'|\'\n  x(a|)\n     ^' ]; then
+  echo "Expected an empty trailing alternative error, got '$err7'"
+  exit 1
+fi
+echo "Test 34 passed."
+echo ""
+
+# --- Run test 35: Every match on a line ---
+echo -e "\033[1m -- Every match on a line -- \033[0m"
+out1=$(echo -n "aabbcdee" | ./ast -o -E "(\w)\1")
+out2=$(echo -n "xabbay" | ./ast -o -E "b*")
+out3=$(echo -n "k=1,k=22" | ./ast -o -E "(?<=k=)\d*")
+
+if [ "$out1" != $'aa\nbb\nee' ]; then
+  echo "Expected 'aa', 'bb' and 'ee' for '(\w)\1', got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "bb" ]; then
+  echo "Expected only 'bb' for 'b*', got '$out2'"
+  exit 1
+fi
+
+if [ "$out3" != $'1\n22' ]; then
+  echo "Expected '1' and '22' for '(?<=k=)\d*', got '$out3'"
+  exit 1
+fi
+echo "Test 35 passed."
+echo ""
+
+# --- Run test 36: Anchoring from the pattern structure ---
+echo -e "\033[1m -- Anchoring from the pattern structure -- \033[0m"
+set +e
+echo -n "xb" | ./ast -E "^a|b"
+code1=$?
+echo -n "x^a" | ./ast -E "\^a"
+code2=$?
+echo -n "ba" | ./ast -E "(^a)"
+code3=$?
+set -e
+out1=$(echo -n "1234 5678" | ./ast -o -E "\d{2}$")
+
+if [ $code1 -ne 0 ]; then
+  echo "Expected exit code 0 for '^a|b', got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 0 ]; then
+  echo "Expected exit code 0 for '\^a', got $code2"
+  exit 1
+fi
+
+if [ $code3 -ne 1 ]; then
+  echo "Expected exit code 1 for '(^a)', got $code3"
+  exit 1
+fi
+
+if [ "$out1" != "78" ]; then
+  echo "Expected '78' for '\d{2}$', got '$out1'"
+  exit 1
+fi
+echo "Test 36 passed."
+echo ""
+
+# --- Run test 37: Atomic groups don't backtrack ---
+echo -e "\033[1m -- Atomic groups don't backtrack -- \033[0m"
+long=$(printf 'a%.0s' $(seq 1 30))
+wide=$(printf 'a%.0s' $(seq 1 50000))
+set +e
+echo -n "${long}!" | timeout 5 ./ast -E "^(?>(\w+\s?)*)$"
+code1=$?
+echo -n "${long}!" | timeout 5 ./ast -E "^(\w+\s?)*+$"
+code2=$?
+echo -n "x${wide}" | timeout 5 ./ast -E "(?>\w+)x"
+code3=$?
+set -e
+
+if [ $code1 -ne 1 ]; then
+  echo "Expected exit code 1 for '^(?>(\w+\s?)*)$', got $code1"
+  exit 1
+fi
+
+if [ $code2 -ne 1 ]; then
+  echo "Expected exit code 1 for '^(\w+\s?)*+$', got $code2"
+  exit 1
+fi
+
+if [ $code3 -ne 1 ]; then
+  echo "Expected exit code 1 for '(?>\w+)x', got $code3"
+  exit 1
+fi
+echo "Test 37 passed."
+echo ""
+
+# --- Run test 38: Reporting named groups ---
+echo -e "\033[1m -- Reporting named groups -- \033[0m"
+out1=$(echo -n "on 2024-01-05 and 2023-12-31" | ./ast --named-groups -E "(?P<year>\d+)-(\d+)-(?<day>\d+)")
+out2=$(echo -n "v1 v2.3" | ./ast --named-groups -E "v(?<major>\d)(\.(?<minor>\d))?")
+
+if [ "$out1" != "$(printf '2024-01-05\tyear=2024\tday=05\n2023-12-31\tyear=2023\tday=31')" ]; then
+  echo "Expected each date with its year and day fields, got '$out1'"
+  exit 1
+fi
+
+if [ "$out2" != "$(printf 'v1\tmajor=1\tminor=\nv2.3\tmajor=2\tminor=3')" ]; then
+  echo "Expected an empty minor field for 'v1', got '$out2'"
+  exit 1
+fi
+echo "Test 38 passed."
+echo ""
+
 # --- Cleanup ----
 rm ast
\ No newline at end of file